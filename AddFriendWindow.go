@@ -0,0 +1,199 @@
+package main
+
+import (
+	prot "github.com/carylorrk/goline/protocol"
+	"html"
+	"strings"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type AddFriendMethod int
+
+const (
+	ADD_BY_USERID AddFriendMethod = 0
+	ADD_BY_EMAIL  AddFriendMethod = 1
+	ADD_BY_PHONE  AddFriendMethod = 2
+	ADD_BY_TICKET AddFriendMethod = 3
+)
+
+type AddFriendWindow struct {
+	Parent *MainWindow
+	Window *gtk.Window
+
+	Table         *gtk.Table
+	Method        *gtk.ComboBoxText
+	Query         *gtk.Entry
+	Search        *gtk.Button
+	Picture       *gtk.Image
+	DisplayName   *gtk.Label
+	StatusMessage *gtk.Label
+	Status        *gtk.Label
+	Add           *gtk.Button
+	Close         *gtk.Button
+
+	found      *prot.Contact
+	foundQuery string
+	method     AddFriendMethod
+}
+
+func NewAddFriendWindow(parent *MainWindow) *AddFriendWindow {
+	addFriendWindow := &AddFriendWindow{Parent: parent}
+	addFriendWindow.setupUI()
+	return addFriendWindow
+}
+
+func (self *AddFriendWindow) setupUI() {
+	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	self.Window.SetTitle("Add Friend")
+	self.Window.SetTransientFor(self.Parent.Window)
+	self.Window.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	self.Window.SetDefaultSize(350, 300)
+
+	self.Method = gtk.NewComboBoxText()
+	self.Method.AppendText("LINE ID")
+	self.Method.AppendText("Email")
+	self.Method.AppendText("Phone")
+	self.Method.AppendText("Invite ticket")
+	self.Method.SetActive(int(ADD_BY_USERID))
+
+	self.Query = gtk.NewEntry()
+	self.Query.Connect("activate", self.search)
+	self.Search = gtk.NewButtonWithLabel("Search")
+	self.Search.Clicked(self.search)
+
+	self.Picture = gtk.NewImage()
+	self.DisplayName = gtk.NewLabel("")
+	self.StatusMessage = gtk.NewLabel("")
+	self.StatusMessage.SetLineWrap(true)
+	self.Status = gtk.NewLabel("Enter a LINE ID, email, phone number or invite ticket.")
+	self.Status.SetLineWrap(true)
+
+	self.Add = gtk.NewButtonWithLabel("Add")
+	self.Add.SetSensitive(false)
+	self.Add.Clicked(self.add)
+	self.Close = gtk.NewButtonWithLabel("Close")
+	self.Close.Clicked(func() {
+		self.Window.Destroy()
+	})
+
+	self.Table = gtk.NewTable(6, 3, false)
+	self.Table.Attach(self.Method, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Query, 1, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Search, 2, 3, 0, 1, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Picture, 0, 3, 1, 2, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 3, 3)
+	self.Table.Attach(self.DisplayName, 0, 3, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.StatusMessage, 0, 3, 3, 4, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Status, 0, 3, 4, 5, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Add, 0, 2, 5, 6, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Close, 2, 3, 5, 6, gtk.FILL, gtk.FILL, 3, 3)
+	self.Window.Add(self.Table)
+}
+
+func (self *AddFriendWindow) setStatus(text string, color string) {
+	self.Status.SetText(text)
+	self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor(color))
+}
+
+func (self *AddFriendWindow) getQuery() string {
+	query := strings.TrimSpace(self.Query.GetText())
+	if AddFriendMethod(self.Method.GetActive()) == ADD_BY_TICKET {
+		query = strings.TrimRight(query, "/")
+		if idx := strings.LastIndex(query, "/"); idx >= 0 {
+			query = query[idx+1:]
+		}
+	}
+	return query
+}
+
+func (self *AddFriendWindow) search() {
+	query := self.getQuery()
+	if query == "" {
+		return
+	}
+	method := AddFriendMethod(self.Method.GetActive())
+	self.found = nil
+	self.Add.SetSensitive(false)
+	self.Search.SetSensitive(false)
+	self.setStatus("Searching...", "blue")
+
+	go func() {
+		var contact *prot.Contact
+		var err error
+		switch method {
+		case ADD_BY_USERID:
+			contact, err = goline.client.FindContactByUserid(query)
+		case ADD_BY_EMAIL:
+			contact, err = goline.client.FindContactByEmail(query)
+		case ADD_BY_PHONE:
+			contact, err = goline.client.FindContactByPhone(query)
+		case ADD_BY_TICKET:
+			contact, err = goline.client.FindContactByUserTicket(query)
+		}
+		var picturePath string
+		if err == nil && contact != nil {
+			picturePath = DownloadContactPicture(contact)
+		}
+
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		self.Search.SetSensitive(true)
+		if err != nil || contact == nil {
+			goline.LoggerPrintln(err)
+			self.DisplayName.SetText("")
+			self.StatusMessage.SetText("")
+			self.Picture.Clear()
+			self.setStatus("No user found.", "red")
+			return
+		}
+		self.found = contact
+		self.foundQuery = query
+		self.method = method
+		self.DisplayName.SetMarkup("<b>" + html.EscapeString(contact.GetDisplayName()) + "</b>")
+		self.StatusMessage.SetText(contact.GetStatusMessage())
+		SetImageFromFileAtScale(self.Picture, picturePath, 96)
+		if goline.client.GetContactById(contact.GetMid()) != nil {
+			self.setStatus("Already in your friends.", "blue")
+			return
+		}
+		self.setStatus("", "blue")
+		self.Add.SetSensitive(true)
+	}()
+}
+
+func (self *AddFriendWindow) add() {
+	if self.found == nil {
+		return
+	}
+	contact := self.found
+	query := self.foundQuery
+	method := self.method
+	self.Add.SetSensitive(false)
+	self.setStatus("Adding...", "blue")
+
+	go func() {
+		var err error
+		switch method {
+		case ADD_BY_USERID:
+			_, err = goline.client.FindAndAddContactsByUserid(query)
+		case ADD_BY_EMAIL:
+			_, err = goline.client.FindAndAddContactsByEmail(query)
+		case ADD_BY_PHONE:
+			_, err = goline.client.FindAndAddContactsByPhone(query)
+		case ADD_BY_TICKET:
+			_, err = goline.client.FindAndAddContactsByMid(contact.GetMid())
+		}
+
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			self.setStatus("Failed to add friend.", "red")
+			self.Add.SetSensitive(true)
+			return
+		}
+		self.setStatus("Added "+contact.GetDisplayName()+".", "blue")
+		self.Parent.rebuildFriendsTable()
+	}()
+}
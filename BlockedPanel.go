@@ -0,0 +1,155 @@
+package main
+
+import (
+	prot "github.com/carylorrk/goline/protocol"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+func (self *MainWindow) setupBlockedPanel() {
+	self.BlockedFrame = gtk.NewFrame("Blocked Users")
+	self.BlockedTable = gtk.NewTable(0, 0, false)
+	self.BlockedFrame.Add(self.BlockedTable)
+	self.MoreTableAttach(self.BlockedFrame)
+	self.refreshBlockedPanel()
+}
+
+func (self *MainWindow) attachBlockedRow(table *gtk.Table, row uint,
+	contact *prot.Contact, action string, callback func(string)) {
+	mid := contact.GetMid()
+	name := contact.GetDisplayName()
+	if name == "" {
+		name = mid
+	}
+	label := gtk.NewLabel(name)
+	label.SetAlignment(0, 0.5)
+	btn := gtk.NewButtonWithLabel(action)
+	btn.Clicked(func() {
+		btn.SetSensitive(false)
+		callback(mid)
+	})
+	table.Attach(label, 0, 1, row, row+1, gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 0)
+	table.Attach(btn, 1, 2, row, row+1, gtk.FILL, gtk.FILL, 2, 0)
+}
+
+func (self *MainWindow) refreshBlockedPanel() {
+	self.BlockedFrame.Remove(self.BlockedTable)
+	self.BlockedTable = gtk.NewTable(0, 0, false)
+	row := uint(0)
+	for _, contact := range goline.client.Blocked {
+		self.attachBlockedRow(self.BlockedTable, row, contact, "Unblock", self.unblockContact)
+		row += 1
+	}
+	for _, contact := range goline.client.Hidden {
+		if goline.client.IsContactBlocked(contact.GetMid()) {
+			continue
+		}
+		self.attachBlockedRow(self.BlockedTable, row, contact, "Unhide", self.unhideContact)
+		row += 1
+	}
+	if row == 0 {
+		self.BlockedTable.Attach(gtk.NewLabel("No blocked or hidden users."),
+			0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 2)
+	}
+	self.BlockedFrame.Add(self.BlockedTable)
+	self.BlockedFrame.ShowAll()
+}
+
+func (self *MainWindow) updateBlocked() {
+	self.rebuildFriendsTable()
+	self.refreshBlockedPanel()
+}
+
+func (self *MainWindow) blockContact(parent *gtk.Window, mid string) bool {
+	name := mid
+	contact := goline.client.GetContactById(mid)
+	if contact != nil {
+		name = contact.GetDisplayName()
+	}
+	if !RunConfirmMessage(parent, "Block "+name+"? You will no longer receive messages from this user.") {
+		return false
+	}
+	go func() {
+		err := goline.client.BlockContact(mid)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(parent, "Failed to block user.")
+			return
+		}
+		self.updateBlocked()
+		chatWindow := self.ChatWindows[mid]
+		if chatWindow != nil {
+			chatWindow.Window.Destroy()
+		}
+	}()
+	return true
+}
+
+func (self *MainWindow) unblockContact(mid string) {
+	go func() {
+		err := goline.client.UnblockContact(mid)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to unblock user.")
+			return
+		}
+		self.updateBlocked()
+	}()
+}
+
+func (self *MainWindow) hideContact(parent *gtk.Window, mid string) {
+	go func() {
+		err := goline.client.HideContact(mid)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(parent, "Failed to hide friend.")
+			return
+		}
+		self.updateBlocked()
+	}()
+}
+
+func (self *MainWindow) unhideContact(mid string) {
+	go func() {
+		err := goline.client.UnhideContact(mid)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to unhide friend.")
+			return
+		}
+		self.updateBlocked()
+	}()
+}
+
+func isContactVisible(contact *prot.Contact) bool {
+	mid := contact.GetMid()
+	return !goline.client.IsContactBlocked(mid) && !goline.client.IsContactHidden(mid)
+}
+
+func (self *MainWindow) handleContactOperation(operation *prot.Operation) {
+	var err error
+	switch operation.GetTypeA1() {
+	case prot.OpType_BLOCK_CONTACT:
+		fallthrough
+	case prot.OpType_UNBLOCK_CONTACT:
+		_, err = goline.client.RefreshBlockedContacts()
+	case prot.OpType_UPDATE_CONTACT:
+		_, err = goline.client.RefreshHiddenContacts()
+	}
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+	gdk.ThreadsEnter()
+	self.updateBlocked()
+	gdk.ThreadsLeave()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+func (self *MainWindow) setupCachePanel() {
+	self.CacheLabel = gtk.NewLabel("")
+	self.CacheLabel.SetAlignment(0, 0.5)
+
+	refresh := gtk.NewButtonWithLabel("Refresh")
+	refresh.Clicked(self.refreshCachePanel)
+	clear := gtk.NewButtonWithLabel("Clear Cache")
+	clear.Clicked(self.clearCache)
+
+	table := gtk.NewTable(2, 2, false)
+	table.Attach(self.CacheLabel, 0, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	table.Attach(refresh, 0, 1, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	table.Attach(clear, 1, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+
+	frame := gtk.NewFrame("Media Cache")
+	frame.Add(table)
+	self.MoreTableAttach(frame)
+	self.refreshCachePanel()
+}
+
+func (self *MainWindow) refreshCachePanel() {
+	self.CacheLabel.SetText("Calculating...")
+	go func() {
+		size, count, err := goline.cache.Usage()
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			self.CacheLabel.SetText("Failed to read cache.")
+			return
+		}
+		self.CacheLabel.SetText(fmt.Sprintf("%s of %s used (%d files)",
+			formatBytes(size), formatBytes(goline.cache.Limit), count))
+	}()
+}
+
+func (self *MainWindow) clearCache() {
+	if !RunConfirmMessage(self.Window, "Remove all cached images, stickers and avatars?") {
+		return
+	}
+	go func() {
+		err := goline.cache.Clear()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			gdk.ThreadsEnter()
+			RunErrorMessage(self.Window, "Failed to clear cache.")
+			gdk.ThreadsLeave()
+		}
+		gdk.ThreadsEnter()
+		self.refreshCachePanel()
+		gdk.ThreadsLeave()
+	}()
+}
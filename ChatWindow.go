@@ -1,8 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"github.com/carylorrk/goline/api"
 	prot "github.com/carylorrk/goline/protocol"
+	"os"
+	"sort"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/mattn/go-gtk/gdk"
@@ -15,15 +20,28 @@ type ChatWindow struct {
 	Window *gtk.Window
 
 	Table           *gtk.Table
-	Conversation    *gtk.Table
+	MenuBar         *gtk.MenuBar
+	ChatMenu        *gtk.Menu
+	Conversation    *gtk.VBox
 	ConversationBox *gtk.EventBox
 	Scroll          *gtk.ScrolledWindow
 	Input           *gtk.Entry
 	Send            *gtk.Button
 
-	Entity       api.LineEntity
-	MessageBox   *prot.TMessageBox
-	MessageCount uint
+	Entity     api.LineEntity
+	MessageBox *prot.TMessageBox
+	Sentences  []*Sentence
+
+	focused       bool
+	lastCheckedId string
+	rows          []*conversationRow
+}
+
+type conversationRow struct {
+	Widget   gtk.IWidget
+	Sentence *Sentence
+	Label    *gtk.Label
+	Date     time.Time
 }
 
 type ChatWindowError int
@@ -69,30 +87,108 @@ func NewChatWindow(parent *MainWindow, entity api.LineEntity) (*ChatWindow, Chat
 
 func (self *ChatWindow) sendTextFromInput() {
 	text := self.Input.GetText()
-	if text != "" {
-		goline.client.SendText(self.Entity.GetId(), text)
-		self.Input.SetText("")
+	if text == "" {
+		return
+	}
+	self.Input.SetText("")
+	pending := self.Parent.Outbox.Add(goline.client.Profile.GetMid(),
+		self.Entity.GetId(), text, goline.client.ServerTimestamp())
+	self.addSentence(pending.Message)
+	self.Conversation.ShowAll()
+	go self.Parent.sendPending(pending.ReqSeq, pending.ChatId, text)
+}
+
+func (self *ChatWindow) resendMessage(localId string) {
+	sentence := self.findSentence(localId)
+	if sentence == nil {
+		return
+	}
+	reqSeq, ok := self.Parent.Outbox.Retry(localId)
+	if !ok {
+		return
+	}
+	sentence.SetSendState(api.SEND_PENDING)
+	go self.Parent.sendPending(reqSeq, self.Entity.GetId(), sentence.Message.GetText())
+}
+
+func (self *ChatWindow) deleteLocalMessage(localId string) {
+	if !self.Parent.Outbox.Remove(localId) {
+		return
+	}
+	self.removeSentence(localId)
+}
+
+func (self *ChatWindow) appendMenuItem(label string, callback func()) *gtk.MenuItem {
+	item := gtk.NewMenuItemWithLabel(label)
+	item.Connect("activate", callback)
+	self.ChatMenu.Append(item)
+	return item
+}
+
+func (self *ChatWindow) setupMenu() {
+	self.MenuBar = gtk.NewMenuBar()
+	self.ChatMenu = gtk.NewMenu()
+	chatItem := gtk.NewMenuItemWithMnemonic("_Chat")
+	chatItem.SetSubmenu(self.ChatMenu)
+	self.MenuBar.Append(chatItem)
+
+	self.appendMenuItem("Export...", func() {
+		NewExportWindow(self).Run()
+	})
+	self.appendMenuItem("Import LINE History...", self.importHistory)
+
+	id := self.Entity.GetId()
+	mute := gtk.NewCheckMenuItemWithLabel("Mute Notifications")
+	mute.SetActive(goline.MutedChats[id])
+	mute.Connect("toggled", func() {
+		err := goline.SetChatMuted(id, mute.GetActive())
+		if err != nil {
+			goline.LoggerPrintln(err)
+		}
+	})
+	self.ChatMenu.Append(mute)
+
+	switch self.Entity.(type) {
+	case *api.LineGroupWrapper:
+		self.appendMenuItem("Group Members...", func() {
+			self.Parent.showGroupWindow(id)
+		})
+		self.appendMenuItem("Leave Group", func() {
+			self.Parent.leaveGroup(self.Window, id)
+		})
+	case *api.LineRoomWrapper:
+		self.appendMenuItem("Invite Friends...", func() {
+			self.Parent.inviteIntoRoom(self.Window, id)
+		})
+		self.appendMenuItem("Leave Chat", func() {
+			self.Parent.leaveRoom(self.Window, id)
+		})
+	case *api.LineContactWrapper:
+		self.appendMenuItem("Hide Friend", func() {
+			self.Parent.hideContact(self.Window, id)
+		})
+		self.appendMenuItem("Block", func() {
+			self.Parent.blockContact(self.Window, id)
+		})
+		self.appendMenuItem("Report Spam...", func() {
+			NewReportSpamWindow(self).Run()
+		})
 	}
 }
 
 func (self *ChatWindow) setupUI() {
 	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
 
-	self.Conversation = gtk.NewTable(0, 0, false)
+	self.setupMenu()
 
 	self.ConversationBox = gtk.NewEventBox()
-	self.ConversationBox.Add(self.Conversation)
 	self.ConversationBox.ModifyBG(gtk.STATE_NORMAL, gdk.NewColorRGB(235, 255, 230))
+	self.setupConversationTable()
 
 	self.Scroll = gtk.NewScrolledWindow(nil, nil)
 	self.Scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
 	self.Scroll.AddWithViewPort(self.ConversationBox)
 
-	self.Conversation.Connect("size-allocate", func() {
-		adj := self.Scroll.GetVAdjustment()
-		adj.SetValue(adj.GetUpper() - adj.GetPageSize())
-	})
-
 	self.Input = gtk.NewEntry()
 	self.Input.Connect("key-press-event", func(ctx *glib.CallbackContext) {
 		arg := ctx.Args(0)
@@ -108,35 +204,311 @@ func (self *ChatWindow) setupUI() {
 	})
 
 	self.Table = gtk.NewTable(0, 0, false)
-	self.Table.Attach(self.Scroll, 0, 5, 0, 1, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 0, 0)
-	self.Table.Attach(self.Input, 0, 4, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
-	self.Table.Attach(self.Send, 4, 5, 1, 2, gtk.FILL, gtk.FILL, 0, 0)
+	self.Table.Attach(self.MenuBar, 0, 5, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	self.Table.Attach(self.Scroll, 0, 5, 1, 2, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 0, 0)
+	self.Table.Attach(self.Input, 0, 4, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	self.Table.Attach(self.Send, 4, 5, 2, 3, gtk.FILL, gtk.FILL, 0, 0)
 
 	self.Window.Add(self.Table)
 }
 
+func (self *ChatWindow) setupConversationTable() {
+	self.Conversation = gtk.NewVBox(false, 0)
+	self.Conversation.Connect("size-allocate", func() {
+		adj := self.Scroll.GetVAdjustment()
+		adj.SetValue(adj.GetUpper() - adj.GetPageSize())
+	})
+	self.ConversationBox.Add(self.Conversation)
+}
+
+func (self *ChatWindow) clearConversation() {
+	self.ConversationBox.Remove(self.Conversation)
+	self.setupConversationTable()
+	self.Sentences = nil
+	self.rows = nil
+	self.lastCheckedId = ""
+	self.ConversationBox.ShowAll()
+}
+
 func (self *ChatWindow) setupWindow() {
 	self.Window.SetTitle(self.Entity.GetName())
 	self.Window.SetPosition(gtk.WIN_POS_MOUSE)
 	self.Window.Resize(400, 500)
 	self.Window.Connect("destroy", func() {
 		self.Parent.ChatWindows[self.Entity.GetId()] = nil
+		self.Parent.Outbox.RemoveChat(self.Entity.GetId())
+	})
+	self.Window.Connect("focus-in-event", func() {
+		self.focused = true
+		if self.Parent.Notifier != nil {
+			go self.Parent.Notifier.Close(self.Entity.GetId())
+		}
+		self.checkChat()
+	})
+	self.Window.Connect("focus-out-event", func() {
+		self.focused = false
 	})
+	self.Scroll.GetVAdjustment().Connect("value-changed", self.checkChat)
+}
+
+func (self *ChatWindow) isScrolledToBottom() bool {
+	adj := self.Scroll.GetVAdjustment()
+	return adj.GetValue() >= adj.GetUpper()-adj.GetPageSize()-1
+}
+
+func (self *ChatWindow) isChecking() bool {
+	return self.focused && self.isScrolledToBottom()
+}
+
+func (self *ChatWindow) getLastMessageId() string {
+	for idx := len(self.Sentences) - 1; idx >= 0; idx-- {
+		messageId := self.Sentences[idx].Message.GetId()
+		if !strings.HasPrefix(messageId, api.IMPORTED_ID_PREFIX) && !api.IsLocalMessageId(messageId) {
+			return messageId
+		}
+	}
+	return ""
+}
+
+func (self *ChatWindow) checkChat() {
+	if !self.isChecking() {
+		return
+	}
+	lastMessageId := self.getLastMessageId()
+	if lastMessageId == "" || lastMessageId == self.lastCheckedId {
+		return
+	}
+	self.lastCheckedId = lastMessageId
+	id := self.Entity.GetId()
+	self.Parent.markChatRead(id)
+	go func() {
+		err := goline.client.SendChatChecked(id, lastMessageId)
+		if err != nil {
+			goline.LoggerPrintln(err)
+		}
+	}()
+}
+
+func (self *ChatWindow) updateReadReceipts() {
+	id := self.Entity.GetId()
+	for _, sentence := range self.Sentences {
+		sentence.UpdateReadCount(self.Parent.ReadReceipts.GetReadCount(id, sentence.Message.GetId()))
+	}
+}
+
+func (self *ChatWindow) insertRow(position int, row *conversationRow, packed bool) {
+	if !packed {
+		self.Conversation.PackStart(row.Widget, false, true, 3)
+	}
+	self.Conversation.ReorderChild(row.Widget, position)
+	self.rows = append(self.rows, nil)
+	copy(self.rows[position+1:], self.rows[position:])
+	self.rows[position] = row
+}
+
+func (self *ChatWindow) removeRow(position int) {
+	self.Conversation.Remove(self.rows[position].Widget)
+	self.rows = append(self.rows[:position], self.rows[position+1:]...)
+}
+
+func (self *ChatWindow) findRow(sentence *Sentence) int {
+	for idx, row := range self.rows {
+		if row.Sentence == sentence {
+			return idx
+		}
+	}
+	return -1
+}
+
+func setDateSeparatorLabel(label *gtk.Label, date time.Time) {
+	label.SetMarkup("<small><b>" + goline.client.FormatMessageDate(date) + "</b></small>")
+}
+
+func (self *ChatWindow) newDateSeparator(date time.Time) *conversationRow {
+	label := gtk.NewLabel("")
+	setDateSeparatorLabel(label, date)
+	label.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("gray"))
+
+	table := gtk.NewTable(1, 3, false)
+	table.Attach(gtk.NewHSeparator(), 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.EXPAND, 5, 0)
+	table.Attach(label, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 5, 0)
+	table.Attach(gtk.NewHSeparator(), 2, 3, 0, 1, gtk.EXPAND|gtk.FILL, gtk.EXPAND, 5, 0)
+	return &conversationRow{Widget: table, Label: label, Date: date}
+}
+
+func (self *ChatWindow) refreshDateSeparators() {
+	for _, row := range self.rows {
+		if row.Label != nil {
+			setDateSeparatorLabel(row.Label, row.Date)
+		}
+	}
+}
+
+func (self *ChatWindow) insertSentence(sentence *Sentence, packed bool) {
+	createdTime := sentence.Message.GetCreatedTime()
+	index := len(self.Sentences)
+	if createdTime > 0 {
+		index = sort.Search(len(self.Sentences), func(idx int) bool {
+			return self.Sentences[idx].Message.GetCreatedTime() > createdTime
+		})
+	}
+	date := goline.client.GetMessageTime(sentence.Message)
+	position := len(self.rows)
+	sameDayAsPrev := false
+	if index > 0 {
+		prev := self.rows[self.findRow(self.Sentences[index-1])]
+		sameDayAsPrev = api.IsSameDay(prev.Date, date)
+	}
+	sameDayAsNext := false
+	if index < len(self.Sentences) {
+		position = self.findRow(self.Sentences[index])
+		sameDayAsNext = api.IsSameDay(self.rows[position].Date, date)
+		if !sameDayAsNext && position > 0 && self.rows[position-1].Sentence == nil {
+			position -= 1
+		}
+	}
+	if !sameDayAsPrev && !sameDayAsNext {
+		self.insertRow(position, self.newDateSeparator(date), false)
+		position += 1
+	}
+	self.insertRow(position, &conversationRow{Widget: sentence.Widget, Sentence: sentence, Date: date}, packed)
+	self.Sentences = append(self.Sentences, nil)
+	copy(self.Sentences[index+1:], self.Sentences[index:])
+	self.Sentences[index] = sentence
+}
+
+func (self *ChatWindow) hasMessage(id string) bool {
+	return self.findSentence(id) != nil
+}
+
+func (self *ChatWindow) findSentence(id string) *Sentence {
+	for _, sentence := range self.Sentences {
+		if sentence.Message.GetId() == id {
+			return sentence
+		}
+	}
+	return nil
+}
+
+func (self *ChatWindow) detachSentence(sentence *Sentence) {
+	for idx, other := range self.Sentences {
+		if other == sentence {
+			self.Sentences = append(self.Sentences[:idx], self.Sentences[idx+1:]...)
+			break
+		}
+	}
+	position := self.findRow(sentence)
+	self.rows = append(self.rows[:position], self.rows[position+1:]...)
+	if position > 0 && self.rows[position-1].Sentence == nil &&
+		(position == len(self.rows) || self.rows[position].Sentence == nil) {
+		self.removeRow(position - 1)
+	}
+}
+
+func (self *ChatWindow) removeSentence(id string) {
+	sentence := self.findSentence(id)
+	if sentence == nil {
+		return
+	}
+	self.detachSentence(sentence)
+	self.Conversation.Remove(sentence.Widget)
+}
+
+func (self *ChatWindow) resolveSentence(localId string, message *prot.Message) {
+	if self.hasMessage(message.GetId()) {
+		self.removeSentence(localId)
+		return
+	}
+	sentence := self.findSentence(localId)
+	if sentence == nil {
+		return
+	}
+	sentence.Resolve(message)
+	self.detachSentence(sentence)
+	self.insertSentence(sentence, true)
+	self.Conversation.ShowAll()
+	self.checkChat()
+}
+
+func (self *ChatWindow) updateSendState(localId string, state api.SendState) {
+	if sentence := self.findSentence(localId); sentence != nil {
+		sentence.SetSendState(state)
+	}
 }
 
 func (self *ChatWindow) addSentence(message *prot.Message) {
-	sentence := NewSentence(self, message)
-	self.Conversation.Attach(
-		sentence.Widget,
-		0, 1,
-		self.MessageCount, self.MessageCount+1,
-		gtk.EXPAND|gtk.FILL, gtk.FILL,
-		3, 3)
-	self.MessageCount += 1
+	if message.GetId() != "" && self.hasMessage(message.GetId()) {
+		return
+	}
+	self.insertSentence(NewSentence(self, message), false)
+	self.checkChat()
 }
 
 func (self *ChatWindow) setupConversation(messages []*prot.Message) {
+	self.setupHistory(messages)
 	for idx := len(messages) - 1; idx >= 0; idx-- {
 		self.addSentence(messages[idx])
 	}
 }
+
+func (self *ChatWindow) setupHistory(messages []*prot.Message) {
+	history, err := goline.history.Load(self.Entity.GetId())
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+	var oldest int64 = 0
+	if len(messages) > 0 {
+		oldest = messages[len(messages)-1].GetCreatedTime()
+	}
+	for _, message := range history {
+		if oldest != 0 && message.GetCreatedTime() >= oldest {
+			break
+		}
+		self.addSentence(message)
+	}
+}
+
+func (self *ChatWindow) importHistory() {
+	dialog := gtk.NewFileChooserDialog("Import LINE History",
+		self.Window,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL,
+		gtk.STOCK_OPEN, gtk.RESPONSE_ACCEPT)
+	filter := gtk.NewFileFilter()
+	filter.SetName("LINE chat history (*.txt)")
+	filter.AddPattern("*.txt")
+	dialog.AddFilter(filter)
+	res := dialog.Run()
+	filePath := dialog.GetFilename()
+	dialog.Destroy()
+	if res != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	go func() {
+		count, err := self.importHistoryFile(filePath)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to import chat history.")
+			return
+		}
+		RunAlertMessage(self.Window, fmt.Sprintf(
+			"Imported %d messages. Reopen the chat to see them.", count))
+	}()
+}
+
+func (self *ChatWindow) importHistoryFile(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	entries, err := api.ParseLineHistory(file)
+	if err != nil {
+		return 0, err
+	}
+	return goline.client.ImportHistory(goline.history, self.Entity, entries)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type ContactSelectWindow struct {
+	Parent *gtk.Window
+	Dialog *gtk.Dialog
+
+	Table     *gtk.Table
+	NameEntry *gtk.Entry
+	Contacts  *gtk.Table
+	Scroll    *gtk.ScrolledWindow
+	Checks    map[string]*gtk.CheckButton
+}
+
+func NewContactSelectWindow(parent *gtk.Window, title string, nameLabel string, exclude []string) *ContactSelectWindow {
+	contactSelectWindow := &ContactSelectWindow{Parent: parent}
+	contactSelectWindow.Checks = make(map[string]*gtk.CheckButton)
+	contactSelectWindow.setupUI(title, nameLabel, exclude)
+	return contactSelectWindow
+}
+
+func (self *ContactSelectWindow) setupUI(title string, nameLabel string, exclude []string) {
+	self.Dialog = gtk.NewDialog()
+	self.Dialog.SetTitle(title)
+	self.Dialog.SetTransientFor(self.Parent)
+	self.Dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	self.Dialog.SetDefaultSize(300, 400)
+
+	excluded := make(map[string]bool)
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+
+	self.Contacts = gtk.NewTable(0, 0, false)
+	var count uint = 0
+	for _, contact := range goline.client.Contacts {
+		if excluded[contact.GetMid()] || !isContactVisible(contact) {
+			continue
+		}
+		check := gtk.NewCheckButtonWithLabel(contact.GetDisplayName())
+		self.Checks[contact.GetMid()] = check
+		self.Contacts.Attach(check, 0, 1, count, count+1, gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 2)
+		count += 1
+	}
+
+	self.Scroll = gtk.NewScrolledWindow(nil, nil)
+	self.Scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	self.Scroll.AddWithViewPort(self.Contacts)
+
+	self.Table = gtk.NewTable(2, 2, false)
+	if nameLabel != "" {
+		label := gtk.NewLabel(nameLabel)
+		label.SetAlignment(0, 0.5)
+		self.NameEntry = gtk.NewEntry()
+		self.Table.Attach(label, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 5, 5)
+		self.Table.Attach(self.NameEntry, 1, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	}
+	self.Table.Attach(self.Scroll, 0, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 5, 5)
+
+	self.Dialog.GetVBox().PackStart(self.Table, true, true, 0)
+	self.Dialog.AddButton(gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL)
+	self.Dialog.AddButton(gtk.STOCK_OK, gtk.RESPONSE_OK)
+}
+
+func (self *ContactSelectWindow) Run() (name string, ids []string, ok bool) {
+	self.Dialog.ShowAll()
+	defer self.Dialog.Destroy()
+	for {
+		if self.Dialog.Run() != gtk.RESPONSE_OK {
+			return "", nil, false
+		}
+		ids = make([]string, 0)
+		for id, check := range self.Checks {
+			if check.GetActive() {
+				ids = append(ids, id)
+			}
+		}
+		if self.NameEntry != nil {
+			name = self.NameEntry.GetText()
+			if name == "" {
+				RunAlertMessage(self.Parent, "Please enter a name.")
+				continue
+			}
+		}
+		if len(ids) == 0 {
+			RunAlertMessage(self.Parent, "Please select at least one friend.")
+			continue
+		}
+		return name, ids, true
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	"path"
+
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type downloadRow struct {
+	Name     *gtk.Label
+	Progress *gtk.ProgressBar
+	Status   *gtk.Label
+	Cancel   *gtk.Button
+	Resume   *gtk.Button
+}
+
+type DownloadsWindow struct {
+	Parent *MainWindow
+	Window *gtk.Window
+
+	Table         *gtk.Table
+	Viewport      *gtk.Viewport
+	Scroll        *gtk.ScrolledWindow
+	ClearFinished *gtk.Button
+	Close         *gtk.Button
+
+	rows map[*api.Download]*downloadRow
+}
+
+func NewDownloadsWindow(parent *MainWindow) *DownloadsWindow {
+	downloadsWindow := &DownloadsWindow{Parent: parent}
+	downloadsWindow.setupUI()
+	downloadsWindow.Refresh()
+	return downloadsWindow
+}
+
+func (self *DownloadsWindow) setupUI() {
+	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	self.Window.SetTitle("Downloads")
+	self.Window.SetPosition(gtk.WIN_POS_MOUSE)
+	self.Window.SetDefaultSize(450, 300)
+	self.Window.Connect("destroy", func() {
+		self.Parent.DownloadsWindow = nil
+	})
+
+	self.Table = gtk.NewTable(0, 0, false)
+	self.Viewport = gtk.NewViewport(nil, nil)
+	self.Viewport.Add(self.Table)
+	self.Scroll = gtk.NewScrolledWindow(nil, nil)
+	self.Scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	self.Scroll.Add(self.Viewport)
+
+	self.ClearFinished = gtk.NewButtonWithLabel("Clear Finished")
+	self.ClearFinished.Clicked(func() {
+		goline.downloads.ClearFinished()
+	})
+	self.Close = gtk.NewButtonWithLabel("Close")
+	self.Close.Clicked(func() {
+		self.Window.Destroy()
+	})
+
+	table := gtk.NewTable(2, 2, false)
+	table.Attach(self.Scroll, 0, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 3, 3)
+	table.Attach(self.ClearFinished, 0, 1, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	table.Attach(self.Close, 1, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Window.Add(table)
+}
+
+func (self *DownloadsWindow) Refresh() {
+	self.Viewport.Remove(self.Table)
+	self.Table = gtk.NewTable(0, 0, false)
+	self.rows = make(map[*api.Download]*downloadRow)
+	downloads := goline.downloads.Downloads()
+	for idx := len(downloads) - 1; idx >= 0; idx-- {
+		self.attachDownload(downloads[idx])
+	}
+	if len(downloads) == 0 {
+		self.Table.Attach(gtk.NewLabel("No downloads."), 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	}
+	self.Viewport.Add(self.Table)
+	self.Viewport.ShowAll()
+}
+
+func (self *DownloadsWindow) attachDownload(download *api.Download) {
+	row := &downloadRow{
+		Name:     gtk.NewLabel(""),
+		Progress: gtk.NewProgressBar(),
+		Status:   gtk.NewLabel(""),
+		Cancel:   gtk.NewButtonWithLabel("Cancel"),
+		Resume:   gtk.NewButtonWithLabel("Resume")}
+	row.Cancel.Clicked(download.Cancel)
+	row.Resume.Clicked(download.Resume)
+	row.Status.SetAlignment(0, 0.5)
+
+	row.Name.SetAlignment(0, 0.5)
+
+	top := uint(len(self.rows) * 2)
+	self.Table.Attach(row.Name, 0, 1, top, top+1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 0)
+	self.Table.Attach(row.Status, 1, 3, top, top+1, gtk.FILL, gtk.FILL, 3, 0)
+	self.Table.Attach(row.Progress, 0, 1, top+1, top+2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(row.Cancel, 1, 2, top+1, top+2, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(row.Resume, 2, 3, top+1, top+2, gtk.FILL, gtk.FILL, 3, 3)
+	self.rows[download] = row
+	self.updateRow(download, row)
+}
+
+func (self *DownloadsWindow) updateRow(download *api.Download, row *downloadRow) {
+	state := download.GetState()
+	received, total := download.GetProgress()
+	row.Name.SetText(path.Base(download.GetFilePath()))
+	text := formatBytes(received)
+	if total > 0 {
+		row.Progress.SetFraction(float64(received) / float64(total))
+		text += " / " + formatBytes(total)
+	} else if state == api.DOWNLOAD_DONE {
+		row.Progress.SetFraction(1)
+	} else {
+		row.Progress.SetFraction(0)
+	}
+	row.Progress.SetText(text)
+
+	status := state.String()
+	if err := download.GetError(); err != nil {
+		status += ": " + err.Error()
+	}
+	row.Status.SetText(status)
+	row.Cancel.SetSensitive(state == api.DOWNLOAD_QUEUED || state == api.DOWNLOAD_ACTIVE)
+	row.Resume.SetSensitive(state == api.DOWNLOAD_FAILED || state == api.DOWNLOAD_CANCELED)
+}
+
+func (self *DownloadsWindow) Update(download *api.Download) {
+	row := self.rows[download]
+	if download == nil || row == nil {
+		self.Refresh()
+		return
+	}
+	self.updateRow(download, row)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"github.com/carylorrk/goline/api"
+	"time"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+const exportDateLayout = "2006-01-02"
+
+type ExportWindow struct {
+	Parent *ChatWindow
+	Dialog *gtk.Dialog
+
+	Table      *gtk.Table
+	Format     *gtk.ComboBoxText
+	SinceEntry *gtk.Entry
+	UntilEntry *gtk.Entry
+	Status     *gtk.Label
+}
+
+func NewExportWindow(parent *ChatWindow) *ExportWindow {
+	exportWindow := &ExportWindow{Parent: parent}
+	exportWindow.setupUI()
+	return exportWindow
+}
+
+func (self *ExportWindow) setupUI() {
+	self.Dialog = gtk.NewDialog()
+	self.Dialog.SetTitle("Export - " + self.Parent.Entity.GetName())
+	self.Dialog.SetTransientFor(self.Parent.Window)
+	self.Dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+
+	self.Format = gtk.NewComboBoxText()
+	for _, format := range []api.ExportFormat{api.EXPORT_JSON, api.EXPORT_HTML, api.EXPORT_TEXT} {
+		self.Format.AppendText(format.String())
+	}
+	self.Format.SetActive(int(api.EXPORT_HTML))
+
+	now := time.Now()
+	self.SinceEntry = gtk.NewEntry()
+	self.SinceEntry.SetText(now.AddDate(0, -1, 0).Format(exportDateLayout))
+	self.UntilEntry = gtk.NewEntry()
+	self.UntilEntry.SetText(now.Format(exportDateLayout))
+
+	self.Status = gtk.NewLabel("Dates are YYYY-MM-DD. Leave empty for no limit.")
+	self.Status.SetAlignment(0, 0.5)
+
+	formatLabel := gtk.NewLabel("Format")
+	formatLabel.SetAlignment(0, 0.5)
+	sinceLabel := gtk.NewLabel("From")
+	sinceLabel.SetAlignment(0, 0.5)
+	untilLabel := gtk.NewLabel("To")
+	untilLabel.SetAlignment(0, 0.5)
+
+	self.Table = gtk.NewTable(4, 2, false)
+	self.Table.Attach(formatLabel, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Format, 1, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(sinceLabel, 0, 1, 1, 2, gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.SinceEntry, 1, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(untilLabel, 0, 1, 2, 3, gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.UntilEntry, 1, 2, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Status, 0, 2, 3, 4, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+
+	self.Dialog.GetVBox().PackStart(self.Table, true, true, 0)
+	self.Dialog.AddButton(gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL)
+	self.Dialog.AddButton("Export", gtk.RESPONSE_OK)
+}
+
+func (self *ExportWindow) parseDate(entry *gtk.Entry) (time.Time, error) {
+	text := entry.GetText()
+	if text == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(exportDateLayout, text, time.Local)
+}
+
+func (self *ExportWindow) Run() {
+	self.Dialog.ShowAll()
+	for {
+		if self.Dialog.Run() != gtk.RESPONSE_OK {
+			self.Dialog.Destroy()
+			return
+		}
+		since, err := self.parseDate(self.SinceEntry)
+		if err != nil {
+			self.Status.SetText("Invalid start date.")
+			self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
+			continue
+		}
+		until, err := self.parseDate(self.UntilEntry)
+		if err != nil {
+			self.Status.SetText("Invalid end date.")
+			self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
+			continue
+		}
+		if !until.IsZero() {
+			until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		format := api.ExportFormat(self.Format.GetActive())
+		self.Dialog.Destroy()
+
+		filePath, ok := self.chooseFile(format)
+		if !ok {
+			return
+		}
+
+		exporter := api.NewChatExporter(goline.client, self.Parent.Entity, format)
+		exporter.Since = since
+		exporter.Until = until
+		exporter.History = goline.history
+		exporter.Download = DownloadFile
+		go self.export(exporter, filePath)
+		return
+	}
+}
+
+func (self *ExportWindow) chooseFile(format api.ExportFormat) (string, bool) {
+	dialog := gtk.NewFileChooserDialog("Export Chat",
+		self.Parent.Window,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL,
+		gtk.STOCK_SAVE, gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+	dialog.SetCurrentName(self.Parent.Entity.GetName() + format.Extension())
+	defer dialog.Destroy()
+	if dialog.Run() != gtk.RESPONSE_ACCEPT {
+		return "", false
+	}
+	return dialog.GetFilename(), true
+}
+
+func (self *ExportWindow) export(exporter *api.ChatExporter, filePath string) {
+	count, err := exporter.Export(filePath)
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		RunErrorMessage(self.Parent.Window, "Failed to export chat.")
+		return
+	}
+	RunAlertMessage(self.Parent.Window, fmt.Sprintf("Exported %d messages to %s.", count, filePath))
+}
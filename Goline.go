@@ -5,6 +5,7 @@ import (
 	"github.com/carylorrk/goline/api"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/user"
 	"path"
@@ -12,14 +13,21 @@ import (
 )
 
 type Goline struct {
-	Id          string          `json:"Id"`
-	Password    string          `json:"Password"`
-	AuthToken   string          `json:"AuthToken"`
-	Remember    bool            `json:"Remember"`
-	DataDirPath string          `json:"-"`
-	TempDirPath string          `json:"-"`
-	client      *api.LineClient `json:"-"`
-	logger      *log.Logger     `json:"-"`
+	Id           string               `json:"Id"`
+	Password     string               `json:"Password"`
+	AuthToken    string               `json:"AuthToken"`
+	Remember     bool                 `json:"Remember"`
+	MutedChats   map[string]bool      `json:"MutedChats"`
+	DoNotDisturb bool                 `json:"DoNotDisturb"`
+	CacheLimitMB int64                `json:"CacheLimitMB"`
+	DataDirPath  string               `json:"-"`
+	TempDirPath  string               `json:"-"`
+	client       *api.LineClient      `json:"-"`
+	history      *api.HistoryStore    `json:"-"`
+	cache        *api.MediaCache      `json:"-"`
+	downloads    *api.DownloadManager `json:"-"`
+	avatars      *api.AvatarCache     `json:"-"`
+	logger       *log.Logger          `json:"-"`
 }
 
 func NewGoline() (goline *Goline, err error) {
@@ -39,6 +47,26 @@ func NewGoline() (goline *Goline, err error) {
 		goline.LoggerPrintln(err)
 		return
 	}
+
+	err = goline.setupHistory()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+
+	goline.setupDownloads()
+
+	err = goline.setupCache()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+
+	err = goline.setupAvatars()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
 	return
 }
 
@@ -105,6 +133,18 @@ func (self *Goline) SaveSettings() error {
 	return err
 }
 
+func (self *Goline) SetChatMuted(id string, muted bool) error {
+	if self.MutedChats == nil {
+		self.MutedChats = make(map[string]bool)
+	}
+	if muted {
+		self.MutedChats[id] = true
+	} else {
+		delete(self.MutedChats, id)
+	}
+	return self.SaveSettings()
+}
+
 func (self *Goline) setupSettings() error {
 	configFile, err := self.loadConfigFile()
 	if err != nil {
@@ -131,6 +171,34 @@ func (self *Goline) setupSettings() error {
 	return err
 }
 
+func (self *Goline) setupHistory() (err error) {
+	self.history, err = api.NewHistoryStore(path.Join(self.DataDirPath, "history"))
+	return
+}
+
+func (self *Goline) setupDownloads() {
+	self.downloads = api.NewDownloadManager(api.DOWNLOAD_DEFAULT_LIMIT, func() *http.Header {
+		if self.client == nil {
+			return &http.Header{}
+		}
+		return self.client.GetHeader()
+	})
+}
+
+func (self *Goline) setupCache() (err error) {
+	self.cache, err = api.NewMediaCache(self.TempDirPath, self.CacheLimitMB*1024*1024, DownloadFile)
+	return
+}
+
+func (self *Goline) setupAvatars() (err error) {
+	self.avatars, err = api.NewAvatarCache(path.Join(self.TempDirPath, api.CACHE_THUMBNAIL), DownloadFile)
+	if err != nil {
+		return
+	}
+	self.avatars.Cache = self.cache
+	return
+}
+
 func (self *Goline) setupLogger() error {
 	logFilePath := path.Join(self.TempDirPath, "log")
 	logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type GroupWindow struct {
+	Parent *MainWindow
+	Window *gtk.Window
+
+	Table           *gtk.Table
+	NameEntry       *gtk.Entry
+	Rename          *gtk.Button
+	MembersTable    *gtk.Table
+	MembersViewport *gtk.Viewport
+	MembersScroll   *gtk.ScrolledWindow
+	MembersCount    uint
+	Invite          *gtk.Button
+	Leave           *gtk.Button
+
+	GroupId string
+}
+
+func NewGroupWindow(parent *MainWindow, groupId string) *GroupWindow {
+	groupWindow := &GroupWindow{Parent: parent, GroupId: groupId}
+	groupWindow.setupUI()
+	if !groupWindow.refresh() {
+		return nil
+	}
+	parent.GroupWindows[groupId] = groupWindow
+	return groupWindow
+}
+
+func (self *GroupWindow) getGroup() *prot.Group {
+	return goline.client.GetGroupById(self.GroupId)
+}
+
+func (self *GroupWindow) setupUI() {
+	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	self.Window.SetPosition(gtk.WIN_POS_MOUSE)
+	self.Window.SetDefaultSize(300, 450)
+	self.Window.Connect("destroy", func() {
+		delete(self.Parent.GroupWindows, self.GroupId)
+	})
+
+	self.NameEntry = gtk.NewEntry()
+	self.Rename = gtk.NewButtonWithLabel("Rename")
+	self.Rename.Clicked(self.rename)
+
+	self.MembersTable = gtk.NewTable(0, 0, false)
+	self.MembersViewport = gtk.NewViewport(nil, nil)
+	self.MembersViewport.Add(self.MembersTable)
+	self.MembersScroll = gtk.NewScrolledWindow(nil, nil)
+	self.MembersScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	self.MembersScroll.Add(self.MembersViewport)
+
+	self.Invite = gtk.NewButtonWithLabel("Invite Friends")
+	self.Invite.Clicked(self.invite)
+	self.Leave = gtk.NewButtonWithLabel("Leave Group")
+	self.Leave.Clicked(func() {
+		self.Parent.leaveGroup(self.Window, self.GroupId)
+	})
+
+	self.Table = gtk.NewTable(3, 2, false)
+	self.Table.Attach(self.NameEntry, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Rename, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.MembersScroll, 0, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 3, 3)
+	self.Table.Attach(self.Invite, 0, 1, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Leave, 1, 2, 2, 3, gtk.FILL, gtk.FILL, 3, 3)
+	self.Window.Add(self.Table)
+}
+
+func (self *GroupWindow) membersTableAttach(label string, action string, callback func()) {
+	nameLabel := gtk.NewLabel(label)
+	nameLabel.SetAlignment(0, 0.5)
+	self.MembersTable.Attach(nameLabel, 0, 1, self.MembersCount, self.MembersCount+1,
+		gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 2)
+	if callback != nil {
+		btn := gtk.NewButtonWithLabel(action)
+		btn.Clicked(callback)
+		self.MembersTable.Attach(btn, 1, 2, self.MembersCount, self.MembersCount+1,
+			gtk.FILL, gtk.FILL, 2, 2)
+	}
+	self.MembersCount += 1
+}
+
+func (self *GroupWindow) refresh() bool {
+	group := self.getGroup()
+	if group == nil {
+		self.Window.Destroy()
+		return false
+	}
+	self.Window.SetTitle(group.GetName())
+	self.NameEntry.SetText(group.GetName())
+
+	self.MembersViewport.Remove(self.MembersTable)
+	self.MembersTable = gtk.NewTable(0, 0, false)
+	self.MembersCount = 0
+	mid := goline.client.Profile.GetMid()
+
+	self.membersTableAttach("Members", "", nil)
+	for _, member := range group.GetMembers() {
+		var callback func()
+		if member.GetMid() != mid {
+			memberId := member.GetMid()
+			memberName := member.GetDisplayName()
+			callback = func() {
+				if !RunConfirmMessage(self.Window, "Kick "+memberName+" out of this group?") {
+					return
+				}
+				self.groupAction("Failed to kick out member.", func() error {
+					return goline.client.KickoutFromGroup(self.GroupId, []string{memberId})
+				})
+			}
+		}
+		self.membersTableAttach(member.GetDisplayName(), "Kick", callback)
+	}
+
+	if len(group.GetInvitee()) > 0 {
+		self.membersTableAttach("Invited", "", nil)
+		for _, invitee := range group.GetInvitee() {
+			inviteeId := invitee.GetMid()
+			self.membersTableAttach(invitee.GetDisplayName(), "Cancel", func() {
+				self.groupAction("Failed to cancel invitation.", func() error {
+					return goline.client.CancelGroupInvitation(self.GroupId, []string{inviteeId})
+				})
+			})
+		}
+	}
+	self.MembersViewport.Add(self.MembersTable)
+	self.MembersViewport.ShowAll()
+	return true
+}
+
+func (self *GroupWindow) groupAction(failure string, action func() error) {
+	go func() {
+		err := action()
+		if err == nil {
+			_, err = goline.client.RefreshGroup(self.GroupId)
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, failure)
+			return
+		}
+		self.Parent.updateGroup(self.GroupId)
+	}()
+}
+
+func (self *GroupWindow) rename() {
+	name := self.NameEntry.GetText()
+	if name == "" {
+		return
+	}
+	self.groupAction("Failed to rename group.", func() error {
+		return goline.client.RenameGroup(self.GroupId, name)
+	})
+}
+
+func (self *GroupWindow) invite() {
+	group := self.getGroup()
+	if group == nil {
+		return
+	}
+	exclude := make([]string, 0)
+	for _, contact := range append(group.GetMembers(), group.GetInvitee()...) {
+		exclude = append(exclude, contact.GetMid())
+	}
+	_, ids, ok := NewContactSelectWindow(self.Window, "Invite Friends", "", exclude).Run()
+	if !ok {
+		return
+	}
+	self.groupAction("Failed to invite friends.", func() error {
+		return goline.client.InviteIntoGroup(self.GroupId, ids)
+	})
+}
+
+func (self *MainWindow) showGroupWindow(groupId string) {
+	groupWindow := self.GroupWindows[groupId]
+	if groupWindow == nil {
+		groupWindow = NewGroupWindow(self, groupId)
+		if groupWindow == nil {
+			RunErrorMessage(self.Window, "Group not found.")
+			return
+		}
+	}
+	groupWindow.Window.ShowAll()
+	groupWindow.Window.Present()
+}
+
+func (self *MainWindow) createGroup() {
+	name, ids, ok := NewContactSelectWindow(self.Window, "New Group", "Group name", nil).Run()
+	if !ok {
+		return
+	}
+	go func() {
+		group, err := goline.client.CreateGroup(name, ids)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to create group.")
+			return
+		}
+		self.rebuildFriendsTable()
+		self.showChatWindowFactory(api.NewLineGroupWrapper(group))()
+	}()
+}
+
+func (self *MainWindow) leaveGroup(parent *gtk.Window, groupId string) {
+	group := goline.client.GetGroupById(groupId)
+	if group == nil {
+		return
+	}
+	if !RunConfirmMessage(parent, "Leave group \""+group.GetName()+"\"?") {
+		return
+	}
+	go func() {
+		err := goline.client.LeaveGroup(groupId)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(parent, "Failed to leave group.")
+			return
+		}
+		self.removeGroup(groupId)
+	}()
+}
+
+func (self *MainWindow) updateGroup(groupId string) {
+	self.rebuildFriendsTable()
+	groupWindow := self.GroupWindows[groupId]
+	if groupWindow != nil {
+		groupWindow.refresh()
+	}
+	chatWindow := self.ChatWindows[groupId]
+	if chatWindow != nil {
+		chatWindow.Window.SetTitle(chatWindow.Entity.GetName())
+	}
+}
+
+func (self *MainWindow) removeGroup(groupId string) {
+	self.rebuildFriendsTable()
+	groupWindow := self.GroupWindows[groupId]
+	if groupWindow != nil {
+		groupWindow.Window.Destroy()
+	}
+	chatWindow := self.ChatWindows[groupId]
+	if chatWindow != nil {
+		chatWindow.Window.Destroy()
+	}
+}
+
+func (self *MainWindow) acceptGroupInvitation(groupId string) {
+	go func() {
+		group, err := goline.client.AcceptGroupInvitation(groupId)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to accept invitation.")
+			self.rebuildFriendsTable()
+			return
+		}
+		self.rebuildFriendsTable()
+		self.showChatWindowFactory(api.NewLineGroupWrapper(group))()
+	}()
+}
+
+func (self *MainWindow) rejectGroupInvitation(groupId string) {
+	go func() {
+		err := goline.client.RejectGroupInvitation(groupId)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to reject invitation.")
+			return
+		}
+		self.rebuildFriendsTable()
+	}()
+}
+
+func (self *MainWindow) handleInvitationOperation(operation *prot.Operation) {
+	groupId := operation.GetParam1()
+	switch operation.GetTypeA1() {
+	case prot.OpType_NOTIFIED_INVITE_INTO_GROUP:
+		_, err := goline.client.RefreshInvitedGroup(groupId)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+	default:
+		goline.client.RemoveInvitedGroup(groupId)
+	}
+	gdk.ThreadsEnter()
+	self.rebuildFriendsTable()
+	gdk.ThreadsLeave()
+}
+
+func (self *MainWindow) handleGroupOperation(operation *prot.Operation) {
+	groupId := operation.GetParam1()
+	mid := goline.client.Profile.GetMid()
+	removed := false
+	switch operation.GetTypeA1() {
+	case prot.OpType_LEAVE_GROUP:
+		removed = true
+	case prot.OpType_NOTIFIED_KICKOUT_FROM_GROUP:
+		removed = api.ContainsMid(operation.GetParam3(), mid)
+	case prot.OpType_NOTIFIED_INVITE_INTO_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_CANCEL_INVITATION_GROUP:
+		if api.ContainsMid(operation.GetParam3(), mid) {
+			self.handleInvitationOperation(operation)
+			return
+		}
+	case prot.OpType_REJECT_GROUP_INVITATION:
+		self.handleInvitationOperation(operation)
+		return
+	case prot.OpType_ACCEPT_GROUP_INVITATION:
+		goline.client.RemoveInvitedGroup(groupId)
+	}
+	if removed {
+		goline.client.RemoveGroup(groupId)
+	} else {
+		_, err := goline.client.RefreshGroup(groupId)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+	}
+	gdk.ThreadsEnter()
+	if removed {
+		self.removeGroup(groupId)
+	} else {
+		self.updateGroup(groupId)
+	}
+	gdk.ThreadsLeave()
+}
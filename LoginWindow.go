@@ -51,7 +51,7 @@ func (self *LoginWindow) CheckAuthToken() {
 				goto errorHandler
 			}
 
-			err = goline.client.AuthTokenLogin(goline.AuthToken)
+			err = LoginWithAuthToken(goline.AuthToken)
 			if err != nil {
 				goto errorHandler
 			}
@@ -235,7 +235,7 @@ func (self *LoginWindow) verify(pincode string) {
 			goline.LoggerPrintln(err)
 			RunAlertMessage(self.Window, "Failed to save new token.")
 		}
-		err = goline.client.AuthTokenLogin(authToken)
+		err = LoginWithAuthToken(authToken)
 		if err != nil {
 			goline.LoggerPrintln(err)
 			gdk.ThreadsEnter()
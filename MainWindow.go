@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/carylorrk/goline/api"
 	prot "github.com/carylorrk/goline/protocol"
+	"html"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,9 +26,28 @@ type MainWindow struct {
 	FriendsScroll   *gtk.ScrolledWindow
 	FriendsCount    uint
 
-	MoreTable *gtk.Table
+	ChatsTable    *gtk.Table
+	ChatsViewport *gtk.Viewport
+	ChatsScroll   *gtk.ScrolledWindow
+	ChatList      *api.ChatList
 
-	ChatWindows map[string]*ChatWindow
+	Notifier *api.Notifier
+	Tray     *api.Tray
+
+	MoreTable    *gtk.Table
+	MoreScroll   *gtk.ScrolledWindow
+	MoreCount    uint
+	ProfilePanel *ProfilePanel
+	BlockedFrame *gtk.Frame
+	BlockedTable *gtk.Table
+	CacheLabel   *gtk.Label
+
+	ChatWindows     map[string]*ChatWindow
+	GroupWindows    map[string]*GroupWindow
+	SettingsWindow  *SettingsWindow
+	DownloadsWindow *DownloadsWindow
+	ReadReceipts    *api.ReadReceipts
+	Outbox          *api.Outbox
 
 	closeChan  chan bool
 	reconnect  uint
@@ -35,13 +57,144 @@ type MainWindow struct {
 func NewMainWindow(parent *LoginWindow) *MainWindow {
 	mainWindow := &MainWindow{Parent: parent}
 	mainWindow.ChatWindows = make(map[string]*ChatWindow)
+	mainWindow.GroupWindows = make(map[string]*GroupWindow)
+	mainWindow.ReadReceipts = api.NewReadReceipts()
+	mainWindow.Outbox = api.NewOutbox()
+	mainWindow.ChatList = api.NewChatList()
 	mainWindow.closeChan = make(chan bool)
 
+	goline.downloads.OnUpdate = mainWindow.updateDownload
 	mainWindow.setupUI()
 	mainWindow.setupFriendsTable()
+	mainWindow.setupNotifier()
+	mainWindow.setupTray()
 	return mainWindow
 }
 
+func (self *MainWindow) setupTray() {
+	var err error
+	self.Tray, err = api.NewTray(goline.DoNotDisturb)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.Tray = nil
+		return
+	}
+	self.Tray.OnActivate = func() {
+		gdk.ThreadsEnter()
+		self.Window.Present()
+		gdk.ThreadsLeave()
+	}
+	self.Tray.OnDoNotDisturb = func(enabled bool) {
+		goline.DoNotDisturb = enabled
+		err := goline.SaveSettings()
+		if err != nil {
+			goline.LoggerPrintln(err)
+		}
+	}
+	self.Tray.OnQuit = func() {
+		gdk.ThreadsEnter()
+		gtk.MainQuit()
+		gdk.ThreadsLeave()
+	}
+}
+
+func (self *MainWindow) setupNotifier() {
+	var err error
+	self.Notifier, err = api.NewNotifier()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.Notifier = nil
+		return
+	}
+	self.Notifier.OnOpen = func(chatId string) {
+		gdk.ThreadsEnter()
+		self.openChat(chatId)
+		gdk.ThreadsLeave()
+	}
+	self.Notifier.OnReply = func(chatId string, text string) {
+		if text != "" {
+			_, err := goline.client.SendText(chatId, text)
+			if err != nil {
+				goline.LoggerPrintln(err)
+			}
+			return
+		}
+		gdk.ThreadsEnter()
+		entity, err := goline.client.GetLineEntityById(chatId)
+		if err != nil || entity == nil {
+			goline.LoggerPrintln(err)
+		} else {
+			NewReplyWindow(self, entity).Window.ShowAll()
+		}
+		gdk.ThreadsLeave()
+	}
+}
+
+func (self *MainWindow) isChatMuted(entity api.LineEntity) bool {
+	if goline.MutedChats[entity.GetId()] {
+		return true
+	}
+	switch v := entity.(type) {
+	case *api.LineGroupWrapper:
+		return v.GetGroup().GetNotificationDisabled()
+	case *api.LineRoomWrapper:
+		return v.GetRoom().GetNotificationDisabled()
+	}
+	return false
+}
+
+func (self *MainWindow) getAvatarFilePath(id string) string {
+	contact := goline.client.GetContactById(id)
+	if contact == nil {
+		return ""
+	}
+	return DownloadContactPicture(contact)
+}
+
+func (self *MainWindow) handleProfileOperation(operation *prot.Operation) {
+	mid := operation.GetParam1()
+	old := goline.client.GetContactById(mid)
+	var oldStatus string
+	if old != nil {
+		oldStatus = old.GetPictureStatus()
+	}
+	contact, err := goline.client.RefreshContact(mid)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+	if contact.GetPictureStatus() != oldStatus {
+		goline.avatars.Invalidate(mid)
+	}
+	gdk.ThreadsEnter()
+	self.rebuildFriendsTable()
+	chatWindow := self.ChatWindows[mid]
+	if chatWindow != nil {
+		chatWindow.Window.SetTitle(contact.GetDisplayName())
+	}
+	gdk.ThreadsLeave()
+}
+
+func (self *MainWindow) notifyMessage(notifier *api.Notifier, entity api.LineEntity, message *prot.Message) {
+	fromId := message.GetFrom()
+	summary := entity.GetName()
+	if _, ok := entity.(*api.LineContactWrapper); !ok {
+		sender, err := goline.client.GetLineEntityById(fromId)
+		if err == nil && sender != nil {
+			summary = sender.GetName() + " (" + entity.GetName() + ")"
+		}
+	}
+	err := notifier.Notify(&api.Notification{
+		ChatId:  entity.GetId(),
+		Summary: summary,
+		Body:    api.MessagePreview(message),
+		Icon:    self.getAvatarFilePath(fromId),
+	})
+	if err != nil {
+		goline.LoggerPrintln(err)
+	}
+}
+
 func (self *MainWindow) parseHttpRequest(str string) (code int, err error) {
 	_, err = fmt.Sscanf(str, "HTTP Response code: %d", &code)
 	return
@@ -66,7 +219,7 @@ func (self *MainWindow) fetchOperations() []*prot.Operation {
 					goline.LoggerPrintln("FetchNewOperations:", err, "NewLineClient:", clientErr)
 					goto errorHandler
 				}
-				clientErr = goline.client.AuthTokenLogin(goline.AuthToken)
+				clientErr = LoginWithAuthToken(goline.AuthToken)
 				if clientErr != nil {
 					goline.LoggerPrintln("FetchNewOperations:", err, "NewLineClient:", clientErr)
 					goto errorHandler
@@ -94,7 +247,19 @@ errorHandler:
 	return nil
 }
 
+func (self *MainWindow) refreshDateSeparators() {
+	gdk.ThreadsEnter()
+	for _, chatWindow := range self.ChatWindows {
+		if chatWindow != nil {
+			chatWindow.refreshDateSeparators()
+		}
+	}
+	gdk.ThreadsLeave()
+}
+
 func (self *MainWindow) runPoll() {
+	lastTimeSync := time.Now()
+	today := goline.client.ServerNow().Local()
 	for {
 		select {
 		case <-self.closeChan:
@@ -107,74 +272,237 @@ func (self *MainWindow) runPoll() {
 					continue
 				}
 				self.reconnect = 0
-				message := operation.GetMessage()
-				opType := operation.GetTypeA1()
-				switch opType {
-				case prot.OpType_SEND_MESSAGE:
-					fallthrough
-				case prot.OpType_SEND_CONTENT:
-					fallthrough
-				case prot.OpType_RECEIVE_MESSAGE:
-					if message != nil {
-						if opType == prot.OpType_SEND_MESSAGE &&
-							(message.ContentType == prot.ContentType_VIDEO ||
-								message.ContentType == prot.ContentType_IMAGE) {
-							continue
-						}
-						if goline.client.Profile == nil {
-							var err error
-							goline.client, err = api.NewLineClient()
-							if err != nil {
-								goline.LoggerPrintln(err)
-								RunErrorMessage(self.Window, "Failed to get new message! Program closed.")
-								gtk.MainQuit()
-							}
-							err = goline.client.AuthTokenLogin(goline.AuthToken)
-							if err != nil {
-								goline.LoggerPrintln(err)
-								RunErrorMessage(self.Window, "Failed to get new message! Program closed.")
-								gtk.MainQuit()
-							}
-						}
-						mid := goline.client.Profile.GetMid()
-						fromId := message.GetFrom()
-						toId := message.GetTo()
-						var id string
-						if fromId == mid {
-							id = toId
-						} else {
-							if toId == mid {
-								id = fromId
-							} else {
-								id = toId
-							}
-						}
-						chatWindow := self.ChatWindows[id]
-						if chatWindow == nil {
-							gdk.ThreadsEnter()
-							entity, err := goline.client.GetLineEntityById(id)
-							if err != nil {
-							}
-							if entity != nil {
-
-								self.showChatWindowFactory(entity)()
-							}
-							gdk.ThreadsLeave()
-						} else {
-							gdk.ThreadsEnter()
-							chatWindow.addSentence(message)
-							chatWindow.Conversation.ShowAll()
-							gdk.ThreadsLeave()
-						}
-					}
-				}
+				self.handleOperation(operation)
 				self.opRevision = revision
 			}
 		}
+		if time.Since(lastTimeSync) >= api.SERVER_TIME_SYNC_INTERVAL {
+			lastTimeSync = time.Now()
+			err := goline.client.SyncServerTime()
+			if err != nil {
+				goline.LoggerPrintln(err)
+			}
+		}
+		if now := goline.client.ServerNow().Local(); !api.IsSameDay(now, today) {
+			today = now
+			self.refreshDateSeparators()
+		}
 		time.Sleep(300 * time.Millisecond)
 	}
 }
 
+func (self *MainWindow) handleOperation(operation *prot.Operation) {
+	if goline.client.IsBlockedOperation(operation) {
+		return
+	}
+	opType := operation.GetTypeA1()
+	switch opType {
+	case prot.OpType_SEND_MESSAGE:
+		self.resolvePending(operation.GetReqSeq(), operation.GetMessage())
+		fallthrough
+	case prot.OpType_SEND_CONTENT:
+		fallthrough
+	case prot.OpType_RECEIVE_MESSAGE:
+		message := operation.GetMessage()
+		if message != nil {
+			self.handleMessage(opType, message)
+		}
+	case prot.OpType_FAILED_SEND_MESSAGE:
+		self.failPending(operation.GetReqSeq(), errors.New("Failed to send message."))
+	case prot.OpType_NOTIFIED_READ_MESSAGE:
+		fallthrough
+	case prot.OpType_RECEIVE_MESSAGE_RECEIPT:
+		if goline.client.Profile == nil {
+			break
+		}
+		chatId, updated := self.ReadReceipts.HandleOperation(
+			goline.client.Profile.GetMid(), operation)
+		if !updated {
+			break
+		}
+		gdk.ThreadsEnter()
+		if chatWindow := self.ChatWindows[chatId]; chatWindow != nil {
+			chatWindow.updateReadReceipts()
+		}
+		gdk.ThreadsLeave()
+	case prot.OpType_CREATE_GROUP:
+		fallthrough
+	case prot.OpType_UPDATE_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_UPDATE_GROUP:
+		fallthrough
+	case prot.OpType_INVITE_INTO_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_INVITE_INTO_GROUP:
+		fallthrough
+	case prot.OpType_LEAVE_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_LEAVE_GROUP:
+		fallthrough
+	case prot.OpType_ACCEPT_GROUP_INVITATION:
+		fallthrough
+	case prot.OpType_NOTIFIED_ACCEPT_GROUP_INVITATION:
+		fallthrough
+	case prot.OpType_KICKOUT_FROM_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_KICKOUT_FROM_GROUP:
+		fallthrough
+	case prot.OpType_CANCEL_INVITATION_GROUP:
+		fallthrough
+	case prot.OpType_NOTIFIED_CANCEL_INVITATION_GROUP:
+		fallthrough
+	case prot.OpType_REJECT_GROUP_INVITATION:
+		if goline.client.Profile != nil {
+			self.handleGroupOperation(operation)
+		}
+	case prot.OpType_CREATE_ROOM:
+		fallthrough
+	case prot.OpType_INVITE_INTO_ROOM:
+		fallthrough
+	case prot.OpType_NOTIFIED_INVITE_INTO_ROOM:
+		fallthrough
+	case prot.OpType_LEAVE_ROOM:
+		fallthrough
+	case prot.OpType_NOTIFIED_LEAVE_ROOM:
+		self.handleRoomOperation(operation)
+	case prot.OpType_BLOCK_CONTACT:
+		fallthrough
+	case prot.OpType_UNBLOCK_CONTACT:
+		fallthrough
+	case prot.OpType_UPDATE_CONTACT:
+		self.handleContactOperation(operation)
+	case prot.OpType_UPDATE_PROFILE:
+		_, err := goline.client.RefreshProfile()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			break
+		}
+		gdk.ThreadsEnter()
+		self.ProfilePanel.Refresh()
+		gdk.ThreadsLeave()
+	case prot.OpType_NOTIFIED_UPDATE_PROFILE:
+		self.handleProfileOperation(operation)
+	case prot.OpType_UPDATE_SETTINGS:
+		if goline.client.IsOwnSettingsOperation(operation) {
+			break
+		}
+		settings, err := goline.client.RefreshSettings()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			break
+		}
+		gdk.ThreadsEnter()
+		if self.SettingsWindow != nil {
+			self.SettingsWindow.Refresh(settings)
+			self.SettingsWindow.setStatus("Settings were changed on another device.", "blue")
+		}
+		gdk.ThreadsLeave()
+	case prot.OpType_SEND_CHAT_REMOVED:
+		gdk.ThreadsEnter()
+		self.clearChat(operation.GetParam1())
+		gdk.ThreadsLeave()
+	case prot.OpType_SEND_CHAT_CHECKED:
+		if self.ChatList.MarkRead(operation.GetParam1()) {
+			gdk.ThreadsEnter()
+			self.refreshChatsTable()
+			gdk.ThreadsLeave()
+		}
+	}
+}
+
+func (self *MainWindow) sendPending(reqSeq int32, chatId string, text string) {
+	message, err := goline.client.SendTextWithSeq(chatId, text, reqSeq)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.failPending(reqSeq, err)
+		return
+	}
+	self.resolvePending(reqSeq, message)
+}
+
+func (self *MainWindow) resolvePending(reqSeq int32, message *prot.Message) {
+	pending := self.Outbox.Resolve(reqSeq, message)
+	if pending == nil {
+		return
+	}
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if chatWindow := self.ChatWindows[pending.ChatId]; chatWindow != nil {
+		chatWindow.resolveSentence(pending.Message.GetId(), message)
+	}
+}
+
+func (self *MainWindow) failPending(reqSeq int32, err error) {
+	pending := self.Outbox.Fail(reqSeq, err)
+	if pending == nil {
+		return
+	}
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if chatWindow := self.ChatWindows[pending.ChatId]; chatWindow != nil {
+		chatWindow.updateSendState(pending.Message.GetId(), api.SEND_FAILED)
+	}
+}
+
+func (self *MainWindow) handleMessage(opType prot.OpType, message *prot.Message) {
+	if opType == prot.OpType_SEND_MESSAGE &&
+		(message.ContentType == prot.ContentType_VIDEO ||
+			message.ContentType == prot.ContentType_IMAGE) {
+		return
+	}
+	if goline.client.Profile == nil {
+		var err error
+		goline.client, err = api.NewLineClient()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to get new message! Program closed.")
+			gtk.MainQuit()
+		}
+		err = LoginWithAuthToken(goline.AuthToken)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to get new message! Program closed.")
+			gtk.MainQuit()
+		}
+	}
+	mid := goline.client.Profile.GetMid()
+	id := api.GetChatIdOfMessage(mid, message)
+	entity, err := goline.client.GetLineEntityById(id)
+	if err != nil {
+		goline.LoggerPrintln(err)
+	}
+	name := ""
+	if entity != nil {
+		name = entity.GetName()
+	}
+	gdk.ThreadsEnter()
+	chatWindow := self.ChatWindows[id]
+	unread := message.GetFrom() != mid && (chatWindow == nil || !chatWindow.isChecking())
+	notifier := self.Notifier
+	notify := notifier != nil && entity != nil && message.GetFrom() != mid &&
+		(chatWindow == nil || !chatWindow.focused) &&
+		!goline.DoNotDisturb && !self.isChatMuted(entity)
+	gdk.ThreadsLeave()
+	self.ChatList.AddMessage(id, name, message, unread)
+
+	if notify {
+		self.notifyMessage(notifier, entity, message)
+	}
+
+	gdk.ThreadsEnter()
+	chatWindow = self.ChatWindows[id]
+	if chatWindow == nil {
+		if entity != nil && self.Notifier == nil {
+			self.showChatWindowFactory(entity)()
+		}
+	} else {
+		chatWindow.addSentence(message)
+		chatWindow.Conversation.ShowAll()
+	}
+	self.refreshChatsTable()
+	gdk.ThreadsLeave()
+}
+
 func (self *MainWindow) refreshFriends() {
 	_, err := goline.client.RefreshContacts()
 	if err != nil {
@@ -191,6 +519,21 @@ func (self *MainWindow) refreshFriends() {
 		RunErrorMessage(self.Window, "Failed to get new data. No refresh.")
 		return
 	}
+	_, err = goline.client.RefreshBlockedContacts()
+	if err != nil {
+		RunErrorMessage(self.Window, "Failed to get new data. No refresh.")
+		return
+	}
+	_, err = goline.client.RefreshHiddenContacts()
+	if err != nil {
+		RunErrorMessage(self.Window, "Failed to get new data. No refresh.")
+		return
+	}
+	self.rebuildFriendsTable()
+	self.refreshBlockedPanel()
+}
+
+func (self *MainWindow) rebuildFriendsTable() {
 	self.FriendsViewport.Remove(self.FriendsTable)
 	self.FriendsTable = gtk.NewTable(0, 0, true)
 	self.FriendsCount = 0
@@ -208,10 +551,66 @@ func (self *MainWindow) setupMoreTab() {
 		self.Parent.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("blue"))
 		self.Parent.Login.SetSensitive(true)
 		self.closeChan <- true
+		if self.Tray != nil {
+			self.Tray.Close()
+			self.Tray = nil
+		}
+		if self.Notifier != nil {
+			self.Notifier.Shutdown()
+			self.Notifier = nil
+		}
 		self.Parent.Window.ShowAll()
 		self.Window.Destroy()
 	})
-	self.MoreTable.Attach(logout, 0, 1, 0, 1, gtk.FILL|gtk.EXPAND, gtk.FILL, 3, 3)
+
+	self.ProfilePanel = NewProfilePanel(self)
+	self.MoreTableAttach(self.ProfilePanel.Frame)
+	self.setupBlockedPanel()
+	self.setupCachePanel()
+
+	downloads := gtk.NewButtonWithLabel("Downloads...")
+	downloads.Clicked(self.showDownloadsWindow)
+	self.MoreTableAttach(downloads)
+
+	settings := gtk.NewButtonWithLabel("Settings...")
+	settings.Clicked(self.showSettingsWindow)
+	self.MoreTableAttach(settings)
+	self.MoreTableAttach(logout)
+}
+
+func (self *MainWindow) MoreTableAttach(widget gtk.IWidget) {
+	self.MoreTable.Attach(
+		widget, 0, 1,
+		self.MoreCount, self.MoreCount+1,
+		gtk.EXPAND|gtk.FILL, gtk.FILL,
+		3, 3)
+	self.MoreCount += 1
+}
+
+func (self *MainWindow) showSettingsWindow() {
+	if self.SettingsWindow != nil {
+		self.SettingsWindow.Window.Present()
+		return
+	}
+	self.SettingsWindow = NewSettingsWindow(self)
+	self.SettingsWindow.Window.ShowAll()
+}
+
+func (self *MainWindow) showDownloadsWindow() {
+	if self.DownloadsWindow != nil {
+		self.DownloadsWindow.Window.Present()
+		return
+	}
+	self.DownloadsWindow = NewDownloadsWindow(self)
+	self.DownloadsWindow.Window.ShowAll()
+}
+
+func (self *MainWindow) updateDownload(download *api.Download) {
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if self.DownloadsWindow != nil {
+		self.DownloadsWindow.Update(download)
+	}
 }
 
 func (self *MainWindow) setupUI() {
@@ -220,6 +619,13 @@ func (self *MainWindow) setupUI() {
 	self.Window.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
 	self.Window.SetTitle("Goline")
 	self.Window.SetDefaultSize(400, 500)
+	self.Window.Connect("delete-event", func() bool {
+		if self.Tray == nil {
+			return false
+		}
+		self.Window.Hide()
+		return true
+	})
 	self.Window.Connect("destroy", func() {
 		if self.Parent.Window.GetVisible() == false {
 			gtk.MainQuit()
@@ -234,12 +640,25 @@ func (self *MainWindow) setupUI() {
 	self.FriendsScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
 	self.FriendsScroll.Add(self.FriendsViewport)
 
-	self.MoreTable = gtk.NewTable(0, 0, true)
+	self.ChatsTable = gtk.NewTable(0, 0, false)
+	self.ChatsViewport = gtk.NewViewport(nil, nil)
+	self.ChatsViewport.Add(self.ChatsTable)
+
+	self.ChatsScroll = gtk.NewScrolledWindow(nil, nil)
+	self.ChatsScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	self.ChatsScroll.Add(self.ChatsViewport)
+
+	self.MoreTable = gtk.NewTable(0, 0, false)
 	self.setupMoreTab()
 
+	self.MoreScroll = gtk.NewScrolledWindow(nil, nil)
+	self.MoreScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	self.MoreScroll.AddWithViewPort(self.MoreTable)
+
 	self.Notebook = gtk.NewNotebook()
 	self.Notebook.AppendPage(self.FriendsScroll, gtk.NewLabel("Friends"))
-	self.Notebook.AppendPage(self.MoreTable, gtk.NewLabel("More"))
+	self.Notebook.AppendPage(self.ChatsScroll, gtk.NewLabel("Chats"))
+	self.Notebook.AppendPage(self.MoreScroll, gtk.NewLabel("More"))
 
 	self.Window.Add(self.Notebook)
 }
@@ -270,9 +689,46 @@ func (self *MainWindow) showChatWindowFactory(entity api.LineEntity) func() {
 }
 
 func (self *MainWindow) attachFriend(entity api.LineEntity) {
-	btn := gtk.NewButtonWithLabel(entity.GetName())
+	avatar := gtk.NewImage()
+	avatar.SetSizeRequest(32, 32)
+	label := gtk.NewLabel(entity.GetName())
+	label.SetAlignment(0, 0.5)
+
+	table := gtk.NewTable(1, 2, false)
+	table.Attach(avatar, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 0, 0)
+	table.Attach(label, 1, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 0)
+
+	btn := gtk.NewButton()
+	btn.Add(table)
 	btn.Clicked(self.showChatWindowFactory(entity))
 	self.FriendsTableAttach(btn)
+
+	friendsTable := self.FriendsTable
+	LoadAvatar(avatar, entity, 32, func() bool {
+		return self.FriendsTable == friendsTable
+	})
+}
+
+func (self *MainWindow) attachInvitation(group *prot.Group) {
+	groupId := group.GetId()
+	label := gtk.NewLabel(group.GetName())
+	label.SetAlignment(0, 0.5)
+	accept := gtk.NewButtonWithLabel("Accept")
+	accept.Clicked(func() {
+		accept.SetSensitive(false)
+		self.acceptGroupInvitation(groupId)
+	})
+	reject := gtk.NewButtonWithLabel("Reject")
+	reject.Clicked(func() {
+		reject.SetSensitive(false)
+		self.rejectGroupInvitation(groupId)
+	})
+
+	table := gtk.NewTable(1, 3, false)
+	table.Attach(label, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 0)
+	table.Attach(accept, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 2, 0)
+	table.Attach(reject, 2, 3, 0, 1, gtk.FILL, gtk.FILL, 2, 0)
+	self.FriendsTableAttach(table)
 }
 
 func (self *MainWindow) setupFriendsTable() {
@@ -280,6 +736,27 @@ func (self *MainWindow) setupFriendsTable() {
 	refresh.Clicked(self.refreshFriends)
 	self.FriendsTableAttach(refresh)
 
+	addFriend := gtk.NewButtonWithLabel("Add Friend")
+	addFriend.Clicked(func() {
+		NewAddFriendWindow(self).Window.ShowAll()
+	})
+	self.FriendsTableAttach(addFriend)
+
+	newChat := gtk.NewButtonWithLabel("New Chat")
+	newChat.Clicked(self.createRoom)
+	self.FriendsTableAttach(newChat)
+
+	newGroup := gtk.NewButtonWithLabel("New Group")
+	newGroup.Clicked(self.createGroup)
+	self.FriendsTableAttach(newGroup)
+
+	if len(goline.client.Invited) > 0 {
+		self.FriendsTableAttach(gtk.NewLabel("Invitations"))
+		for _, group := range goline.client.Invited {
+			self.attachInvitation(group)
+		}
+	}
+
 	self.FriendsTableAttach(gtk.NewLabel("Groups"))
 	for _, group := range goline.client.Groups {
 		entity := api.NewLineGroupWrapper(group)
@@ -294,12 +771,245 @@ func (self *MainWindow) setupFriendsTable() {
 
 	self.FriendsTableAttach(gtk.NewLabel("Contacts"))
 	for _, contact := range goline.client.Contacts {
+		if !isContactVisible(contact) {
+			continue
+		}
 		entity := api.NewLineContactWrapper(contact)
 		self.attachFriend(entity)
 	}
 }
 
+func (self *MainWindow) formatChatTime(chatTime time.Time) string {
+	if chatTime.IsZero() {
+		return ""
+	}
+	chatTime = chatTime.Local()
+	now := time.Now()
+	if chatTime.YearDay() == now.YearDay() && chatTime.Year() == now.Year() {
+		return chatTime.Format("15:04")
+	}
+	return chatTime.Format("2006/01/02")
+}
+
+func (self *MainWindow) formatChatPreview(message *prot.Message) string {
+	preview := []rune(strings.Replace(api.MessagePreview(message), "\n", " ", -1))
+	if len(preview) > 40 {
+		preview = append(preview[:40], []rune("...")...)
+	}
+	return string(preview)
+}
+
+func (self *MainWindow) newChatRow(chat *api.ChatEntry) gtk.IWidget {
+	markup := "<b>" + html.EscapeString(chat.Name) + "</b>"
+	if chat.UnreadCount > 0 {
+		markup += fmt.Sprintf(
+			"  <span foreground=\"white\" background=\"red\"><b> %d </b></span>",
+			chat.UnreadCount)
+	}
+	markup += "\n<small>" + html.EscapeString(self.formatChatPreview(chat.LastMessage)) + "</small>"
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
+	label.SetAlignment(0, 0.5)
+
+	timeLabel := gtk.NewLabel("")
+	timeLabel.SetMarkup("<small>" + self.formatChatTime(chat.LastTime) + "</small>")
+	timeLabel.SetAlignment(1, 0)
+
+	table := gtk.NewTable(1, 2, false)
+	table.Attach(label, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	table.Attach(timeLabel, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 0, 0)
+
+	btn := gtk.NewButton()
+	btn.Add(table)
+	id := chat.Id
+	btn.Clicked(func() {
+		self.openChat(id)
+	})
+	return btn
+}
+
+func (self *MainWindow) refreshChatsTable() {
+	self.ChatsViewport.Remove(self.ChatsTable)
+	self.ChatsTable = gtk.NewTable(0, 0, false)
+	for idx, chat := range self.ChatList.Sorted() {
+		self.ChatsTable.Attach(
+			self.newChatRow(chat), 0, 1,
+			uint(idx), uint(idx+1),
+			gtk.EXPAND|gtk.FILL, gtk.FILL,
+			2, 2)
+	}
+	self.ChatsViewport.Add(self.ChatsTable)
+	self.ChatsViewport.ShowAll()
+	if self.Tray != nil {
+		go self.Tray.SetUnreadCount(self.ChatList.GetUnreadCount())
+	}
+}
+
+func (self *MainWindow) markChatRead(id string) {
+	if self.ChatList.MarkRead(id) {
+		self.refreshChatsTable()
+	}
+}
+
+func (self *MainWindow) openChat(id string) {
+	chatWindow := self.ChatWindows[id]
+	if chatWindow != nil {
+		chatWindow.Window.Present()
+		return
+	}
+	entity, err := goline.client.GetLineEntityById(id)
+	if err == nil && entity == nil {
+		var contact *prot.Contact
+		contact, err = goline.client.FetchContact(id)
+		if err == nil {
+			entity = api.NewLineContactWrapper(contact)
+		}
+	}
+	if err != nil || entity == nil {
+		goline.LoggerPrintln(err)
+		RunErrorMessage(self.Window, "Failed to create chat window.")
+		return
+	}
+	self.showChatWindowFactory(entity)()
+}
+
+func (self *MainWindow) removeChatMessages(id string, lastMessageId string) error {
+	if lastMessageId != "" {
+		err := goline.client.SendChatRemoved(id, lastMessageId)
+		if err != nil {
+			return err
+		}
+	}
+	return goline.history.Remove(id)
+}
+
+func (self *MainWindow) clearChat(id string) {
+	chatWindow := self.ChatWindows[id]
+	if chatWindow != nil {
+		chatWindow.clearConversation()
+	}
+	if self.ChatList.Remove(id) {
+		self.refreshChatsTable()
+	}
+}
+
+func (self *MainWindow) loadChats() {
+	err := self.ChatList.Refresh(goline.client)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+	gdk.ThreadsEnter()
+	self.refreshChatsTable()
+	gdk.ThreadsLeave()
+}
+
+func (self *MainWindow) createRoom() {
+	_, ids, ok := NewContactSelectWindow(self.Window, "New Chat", "", nil).Run()
+	if !ok {
+		return
+	}
+	if len(ids) == 1 {
+		self.openChat(ids[0])
+		return
+	}
+	go func() {
+		room, err := goline.client.CreateRoom(ids)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(self.Window, "Failed to create chat.")
+			return
+		}
+		self.rebuildFriendsTable()
+		self.showChatWindowFactory(api.NewLineRoomWrapper(room))()
+	}()
+}
+
+func (self *MainWindow) inviteIntoRoom(parent *gtk.Window, roomId string) {
+	room := goline.client.GetRoomById(roomId)
+	if room == nil {
+		return
+	}
+	exclude := make([]string, 0)
+	for _, contact := range room.GetContacts() {
+		exclude = append(exclude, contact.GetMid())
+	}
+	_, ids, ok := NewContactSelectWindow(parent, "Invite Friends", "", exclude).Run()
+	if !ok {
+		return
+	}
+	go func() {
+		err := goline.client.InviteIntoRoom(roomId, ids)
+		if err == nil {
+			_, err = goline.client.RefreshRoom(roomId)
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(parent, "Failed to invite friends.")
+			return
+		}
+		self.updateRoom(roomId)
+	}()
+}
+
+func (self *MainWindow) leaveRoom(parent *gtk.Window, roomId string) {
+	if !RunConfirmMessage(parent, "Leave this chat?") {
+		return
+	}
+	go func() {
+		err := goline.client.LeaveRoom(roomId)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			RunErrorMessage(parent, "Failed to leave chat.")
+			return
+		}
+		self.removeRoom(roomId)
+	}()
+}
+
+func (self *MainWindow) updateRoom(roomId string) {
+	self.rebuildFriendsTable()
+	chatWindow := self.ChatWindows[roomId]
+	if chatWindow != nil {
+		chatWindow.Window.SetTitle(chatWindow.Entity.GetName())
+	}
+}
+
+func (self *MainWindow) removeRoom(roomId string) {
+	self.rebuildFriendsTable()
+	chatWindow := self.ChatWindows[roomId]
+	if chatWindow != nil {
+		chatWindow.Window.Destroy()
+	}
+}
+
+func (self *MainWindow) handleRoomOperation(operation *prot.Operation) {
+	roomId := operation.GetParam1()
+	if operation.GetTypeA1() == prot.OpType_LEAVE_ROOM {
+		goline.client.RemoveRoom(roomId)
+		gdk.ThreadsEnter()
+		self.removeRoom(roomId)
+		gdk.ThreadsLeave()
+		return
+	}
+	_, err := goline.client.RefreshRoom(roomId)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		return
+	}
+	gdk.ThreadsEnter()
+	self.updateRoom(roomId)
+	gdk.ThreadsLeave()
+}
+
 func (self *MainWindow) ShowAll() {
 	self.Window.ShowAll()
+	go self.loadChats()
 	go self.runPoll()
 }
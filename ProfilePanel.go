@@ -0,0 +1,224 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
+	"strings"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type ProfilePanel struct {
+	Parent *MainWindow
+	Frame  *gtk.Frame
+
+	Table          *gtk.Table
+	Picture        *gtk.Image
+	ChangePicture  *gtk.Button
+	DisplayName    *gtk.Entry
+	SaveName       *gtk.Button
+	StatusMessage  *gtk.Entry
+	SaveStatus     *gtk.Button
+	Userid         *gtk.Entry
+	CheckUserid    *gtk.Button
+	RegisterUserid *gtk.Button
+	Status         *gtk.Label
+
+	pictureStatus string
+}
+
+func NewProfilePanel(parent *MainWindow) *ProfilePanel {
+	profilePanel := &ProfilePanel{Parent: parent}
+	profilePanel.setupUI()
+	profilePanel.Refresh()
+	return profilePanel
+}
+
+func (self *ProfilePanel) setupUI() {
+	self.Frame = gtk.NewFrame("Profile")
+
+	self.Picture = gtk.NewImage()
+	self.ChangePicture = gtk.NewButtonWithLabel("Change Picture...")
+	self.ChangePicture.Clicked(self.changePicture)
+
+	self.DisplayName = gtk.NewEntry()
+	self.SaveName = gtk.NewButtonWithLabel("Save")
+	self.SaveName.Clicked(func() {
+		self.updateAttribute(prot.ProfileAttribute_DISPLAY_NAME, self.DisplayName.GetText())
+	})
+
+	self.StatusMessage = gtk.NewEntry()
+	self.SaveStatus = gtk.NewButtonWithLabel("Save")
+	self.SaveStatus.Clicked(func() {
+		self.updateAttribute(prot.ProfileAttribute_STATUS_MESSAGE, self.StatusMessage.GetText())
+	})
+
+	self.Userid = gtk.NewEntry()
+	self.CheckUserid = gtk.NewButtonWithLabel("Check")
+	self.CheckUserid.Clicked(self.checkUserid)
+	self.RegisterUserid = gtk.NewButtonWithLabel("Register")
+	self.RegisterUserid.Clicked(self.registerUserid)
+
+	self.Status = gtk.NewLabel("")
+	self.Status.SetAlignment(0, 0.5)
+
+	nameLabel := gtk.NewLabel("Name")
+	nameLabel.SetAlignment(0, 0.5)
+	statusLabel := gtk.NewLabel("Status")
+	statusLabel.SetAlignment(0, 0.5)
+	useridLabel := gtk.NewLabel("LINE ID")
+	useridLabel.SetAlignment(0, 0.5)
+
+	useridTable := gtk.NewTable(1, 3, false)
+	useridTable.Attach(self.Userid, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	useridTable.Attach(self.CheckUserid, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 2, 0)
+	useridTable.Attach(self.RegisterUserid, 2, 3, 0, 1, gtk.FILL, gtk.FILL, 2, 0)
+
+	self.Table = gtk.NewTable(6, 3, false)
+	self.Table.Attach(self.Picture, 0, 3, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.ChangePicture, 0, 3, 1, 2, gtk.EXPAND, gtk.FILL, 3, 3)
+	self.Table.Attach(nameLabel, 0, 1, 2, 3, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.DisplayName, 1, 2, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.SaveName, 2, 3, 2, 3, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(statusLabel, 0, 1, 3, 4, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.StatusMessage, 1, 2, 3, 4, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.SaveStatus, 2, 3, 3, 4, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(useridLabel, 0, 1, 4, 5, gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(useridTable, 1, 3, 4, 5, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Table.Attach(self.Status, 0, 3, 5, 6, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	self.Frame.Add(self.Table)
+}
+
+func (self *ProfilePanel) setStatus(text string, color string) {
+	self.Status.SetText(text)
+	self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor(color))
+}
+
+func (self *ProfilePanel) Refresh() {
+	profile := goline.client.Profile
+	if profile == nil {
+		return
+	}
+	self.DisplayName.SetText(profile.GetDisplayName())
+	self.StatusMessage.SetText(profile.GetStatusMessage())
+
+	userid := profile.GetUserid()
+	self.Userid.SetText(userid)
+	self.Userid.SetEditable(userid == "")
+	self.CheckUserid.SetSensitive(userid == "")
+	self.RegisterUserid.SetSensitive(userid == "")
+
+	pictureStatus := profile.GetPictureStatus()
+	if pictureStatus == self.pictureStatus {
+		return
+	}
+	self.pictureStatus = pictureStatus
+	mid := profile.GetMid()
+	go func() {
+		filePath := DownloadPicture(mid, pictureStatus, api.GetProfilePictureUrl(pictureStatus))
+		gdk.ThreadsEnter()
+		SetImageFromFileAtScale(self.Picture, filePath, 96)
+		gdk.ThreadsLeave()
+	}()
+}
+
+func (self *ProfilePanel) finishUpdate(err error, failure string, success string) {
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.setStatus(failure, "red")
+		return
+	}
+	self.setStatus(success, "blue")
+	self.Refresh()
+}
+
+func (self *ProfilePanel) updateAttribute(attr prot.ProfileAttribute, value string) {
+	if attr == prot.ProfileAttribute_DISPLAY_NAME && strings.TrimSpace(value) == "" {
+		self.setStatus("Name cannot be empty.", "red")
+		return
+	}
+	self.setStatus("Saving...", "blue")
+	go func() {
+		_, err := goline.client.UpdateProfileAttribute(attr, value)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		self.finishUpdate(err, "Failed to update profile.", "Profile updated.")
+	}()
+}
+
+func (self *ProfilePanel) checkUserid() {
+	userid := strings.TrimSpace(self.Userid.GetText())
+	if userid == "" {
+		return
+	}
+	self.setStatus("Checking...", "blue")
+	go func() {
+		available, err := goline.client.IsUseridAvailable(userid)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			self.setStatus("Failed to check LINE ID.", "red")
+		} else if available {
+			self.setStatus(userid+" is available.", "blue")
+		} else {
+			self.setStatus(userid+" is already taken.", "red")
+		}
+	}()
+}
+
+func (self *ProfilePanel) registerUserid() {
+	userid := strings.TrimSpace(self.Userid.GetText())
+	if userid == "" {
+		return
+	}
+	if !RunConfirmMessage(self.Parent.Window, "Register "+userid+" as your LINE ID? It cannot be changed later.") {
+		return
+	}
+	self.setStatus("Registering...", "blue")
+	go func() {
+		available, err := goline.client.IsUseridAvailable(userid)
+		if err == nil && !available {
+			gdk.ThreadsEnter()
+			self.setStatus(userid+" is already taken.", "red")
+			gdk.ThreadsLeave()
+			return
+		}
+		if err == nil {
+			_, err = goline.client.RegisterUserid(userid)
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		self.finishUpdate(err, "Failed to register LINE ID.", "LINE ID registered.")
+	}()
+}
+
+func (self *ProfilePanel) changePicture() {
+	dialog := gtk.NewFileChooserDialog("Change Picture",
+		self.Parent.Window,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL,
+		gtk.STOCK_OPEN, gtk.RESPONSE_ACCEPT)
+	filter := gtk.NewFileFilter()
+	filter.SetName("Images")
+	filter.AddPattern("*.jpg")
+	filter.AddPattern("*.jpeg")
+	filter.AddPattern("*.png")
+	dialog.AddFilter(filter)
+	res := dialog.Run()
+	filePath := dialog.GetFilename()
+	dialog.Destroy()
+	if res != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	self.setStatus("Uploading...", "blue")
+	self.ChangePicture.SetSensitive(false)
+	go func() {
+		_, err := goline.client.UpdateProfilePicture(filePath)
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		self.ChangePicture.SetSensitive(true)
+		self.finishUpdate(err, "Failed to upload picture.", "Picture updated.")
+	}()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	"unsafe"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type ReplyWindow struct {
+	Parent *MainWindow
+	Window *gtk.Window
+
+	Table *gtk.Table
+	Title *gtk.Label
+	Input *gtk.Entry
+	Send  *gtk.Button
+	Open  *gtk.Button
+
+	Entity api.LineEntity
+}
+
+func NewReplyWindow(parent *MainWindow, entity api.LineEntity) *ReplyWindow {
+	replyWindow := &ReplyWindow{Parent: parent, Entity: entity}
+	replyWindow.setupUI()
+	return replyWindow
+}
+
+func (self *ReplyWindow) send() {
+	text := self.Input.GetText()
+	if text == "" {
+		return
+	}
+	self.Window.Destroy()
+	id := self.Entity.GetId()
+	go func() {
+		_, err := goline.client.SendText(id, text)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			gdk.ThreadsEnter()
+			RunErrorMessage(self.Parent.Window, "Failed to send message.")
+			gdk.ThreadsLeave()
+		}
+	}()
+}
+
+func (self *ReplyWindow) setupUI() {
+	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	self.Window.SetTitle("Reply - " + self.Entity.GetName())
+	self.Window.SetPosition(gtk.WIN_POS_MOUSE)
+	self.Window.SetDefaultSize(350, 0)
+	self.Window.SetKeepAbove(true)
+
+	self.Title = gtk.NewLabel("Reply to " + self.Entity.GetName())
+	self.Title.SetAlignment(0, 0.5)
+
+	self.Input = gtk.NewEntry()
+	self.Input.Connect("key-press-event", func(ctx *glib.CallbackContext) {
+		arg := ctx.Args(0)
+		key := *(**gdk.EventKey)(unsafe.Pointer(&arg))
+		if key.Keyval == gdk.KEY_Return || key.Keyval == gdk.KEY_KP_Enter {
+			self.send()
+		}
+	})
+
+	self.Send = gtk.NewButtonWithLabel("Send")
+	self.Send.Clicked(self.send)
+
+	self.Open = gtk.NewButtonWithLabel("Open Chat")
+	self.Open.Clicked(func() {
+		self.Window.Destroy()
+		self.Parent.openChat(self.Entity.GetId())
+	})
+
+	self.Table = gtk.NewTable(3, 2, false)
+	self.Table.Attach(self.Title, 0, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Input, 0, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Open, 0, 1, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Send, 1, 2, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+
+	self.Window.Add(self.Table)
+	self.Input.GrabFocus()
+}
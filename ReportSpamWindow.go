@@ -0,0 +1,197 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
+	"strings"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+const reportSpamMessageLimit = 50
+
+type ReportSpamWindow struct {
+	Parent *ChatWindow
+	Dialog *gtk.Dialog
+
+	Table    *gtk.Table
+	Messages []*gtk.CheckButton
+	Reasons  []*gtk.CheckButton
+	Block    *gtk.CheckButton
+	Clear    *gtk.CheckButton
+	Status   *gtk.Label
+
+	messageIds []string
+}
+
+func NewReportSpamWindow(parent *ChatWindow) *ReportSpamWindow {
+	reportSpamWindow := &ReportSpamWindow{Parent: parent}
+	reportSpamWindow.setupUI()
+	return reportSpamWindow
+}
+
+func (self *ReportSpamWindow) getSpamSentences() []*Sentence {
+	mid := self.Parent.Entity.GetId()
+	sentences := make([]*Sentence, 0)
+	for _, sentence := range self.Parent.Sentences {
+		message := sentence.Message
+		if message.GetFrom() != mid ||
+			strings.HasPrefix(message.GetId(), api.IMPORTED_ID_PREFIX) {
+			continue
+		}
+		sentences = append(sentences, sentence)
+	}
+	if len(sentences) > reportSpamMessageLimit {
+		sentences = sentences[len(sentences)-reportSpamMessageLimit:]
+	}
+	return sentences
+}
+
+func (self *ReportSpamWindow) setupUI() {
+	self.Dialog = gtk.NewDialog()
+	self.Dialog.SetTitle("Report Spam - " + self.Parent.Entity.GetName())
+	self.Dialog.SetTransientFor(self.Parent.Window)
+	self.Dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	self.Dialog.SetDefaultSize(400, 450)
+
+	messageTable := gtk.NewTable(0, 0, false)
+	sentences := self.getSpamSentences()
+	for idx, sentence := range sentences {
+		message := sentence.Message
+		text := api.MessageTime(message).Local().Format("01/02 15:04") +
+			"  " + api.MessagePreview(message)
+		check := gtk.NewCheckButtonWithLabel(text)
+		messageTable.Attach(check, 0, 1, uint(idx), uint(idx+1), gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 0)
+		self.Messages = append(self.Messages, check)
+		self.messageIds = append(self.messageIds, message.GetId())
+	}
+	if len(sentences) == 0 {
+		messageTable.Attach(gtk.NewLabel("No messages from this user."),
+			0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 0)
+	}
+	messageScroll := gtk.NewScrolledWindow(nil, nil)
+	messageScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	messageScroll.AddWithViewPort(messageTable)
+
+	reasonTable := gtk.NewTable(0, 0, false)
+	for idx, reason := range api.SpammerReasons {
+		check := gtk.NewCheckButtonWithLabel(api.SpammerReasonName(reason))
+		reasonTable.Attach(check, 0, 1, uint(idx), uint(idx+1), gtk.EXPAND|gtk.FILL, gtk.FILL, 2, 0)
+		self.Reasons = append(self.Reasons, check)
+	}
+
+	self.Block = gtk.NewCheckButtonWithLabel("Block this user")
+	self.Block.SetActive(true)
+	self.Clear = gtk.NewCheckButtonWithLabel("Clear this chat")
+
+	self.Status = gtk.NewLabel("Select the offending messages and at least one reason.")
+	self.Status.SetAlignment(0, 0.5)
+
+	messagesLabel := gtk.NewLabel("Messages")
+	messagesLabel.SetAlignment(0, 0.5)
+	reasonsLabel := gtk.NewLabel("Reasons")
+	reasonsLabel.SetAlignment(0, 0.5)
+
+	self.Table = gtk.NewTable(7, 1, false)
+	self.Table.Attach(messagesLabel, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(messageScroll, 0, 1, 1, 2, gtk.EXPAND|gtk.FILL, gtk.EXPAND|gtk.FILL, 5, 5)
+	self.Table.Attach(reasonsLabel, 0, 1, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(reasonTable, 0, 1, 3, 4, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Block, 0, 1, 4, 5, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 0)
+	self.Table.Attach(self.Clear, 0, 1, 5, 6, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 0)
+	self.Table.Attach(self.Status, 0, 1, 6, 7, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+
+	self.Dialog.GetVBox().PackStart(self.Table, true, true, 0)
+	self.Dialog.AddButton(gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL)
+	self.Dialog.AddButton("Report", gtk.RESPONSE_OK)
+}
+
+func (self *ReportSpamWindow) getSelectedMessageIds() []string {
+	ids := make([]string, 0)
+	for idx, check := range self.Messages {
+		if check.GetActive() {
+			ids = append(ids, self.messageIds[idx])
+		}
+	}
+	return ids
+}
+
+func (self *ReportSpamWindow) getSelectedReasons() []prot.SpammerReason {
+	reasons := make([]prot.SpammerReason, 0)
+	for idx, check := range self.Reasons {
+		if check.GetActive() {
+			reasons = append(reasons, api.SpammerReasons[idx])
+		}
+	}
+	return reasons
+}
+
+func (self *ReportSpamWindow) Run() {
+	self.Dialog.ShowAll()
+	for {
+		if self.Dialog.Run() != gtk.RESPONSE_OK {
+			self.Dialog.Destroy()
+			return
+		}
+		reasons := self.getSelectedReasons()
+		if len(reasons) == 0 {
+			self.Status.SetText("Please pick at least one reason.")
+			self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
+			continue
+		}
+		messageIds := self.getSelectedMessageIds()
+		block := self.Block.GetActive()
+		clear := self.Clear.GetActive()
+		self.Dialog.Destroy()
+		lastMessageId := self.Parent.getLastMessageId()
+		go self.report(reasons, messageIds, lastMessageId, block, clear)
+		return
+	}
+}
+
+func (self *ReportSpamWindow) report(reasons []prot.SpammerReason, messageIds []string, lastMessageId string, block bool, clear bool) {
+	chatWindow := self.Parent
+	mainWindow := chatWindow.Parent
+	mid := chatWindow.Entity.GetId()
+
+	err := goline.client.ReportSpammer(mid, reasons, messageIds)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		gdk.ThreadsEnter()
+		RunErrorMessage(chatWindow.Window, "Failed to report spam.")
+		gdk.ThreadsLeave()
+		return
+	}
+
+	var clearErr error
+	if clear {
+		clearErr = mainWindow.removeChatMessages(mid, lastMessageId)
+	}
+	var blockErr error
+	if block {
+		blockErr = goline.client.BlockContact(mid)
+	}
+
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if clear {
+		if clearErr != nil {
+			goline.LoggerPrintln(clearErr)
+			RunErrorMessage(chatWindow.Window, "Failed to clear chat.")
+		} else {
+			mainWindow.clearChat(mid)
+		}
+	}
+	if block {
+		if blockErr != nil {
+			goline.LoggerPrintln(blockErr)
+			RunErrorMessage(chatWindow.Window, "Failed to block user.")
+		} else {
+			mainWindow.updateBlocked()
+			chatWindow.Window.Destroy()
+			return
+		}
+	}
+	RunAlertMessage(chatWindow.Window, "Thank you for your report.")
+}
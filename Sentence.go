@@ -3,43 +3,316 @@ package main
 import (
 	"github.com/carylorrk/goline/api"
 	prot "github.com/carylorrk/goline/protocol"
-	"path"
+	"html"
+	"strconv"
+	"strings"
 
 	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/glib"
 	"github.com/mattn/go-gtk/gtk"
 	"github.com/mattn/go-gtk/pango"
 )
 
+const EMOTICON_SIZE = 20
+
 type Sentence struct {
 	Parent  *ChatWindow
 	Message *prot.Message
 
-	Widget gtk.IWidget
+	Widget    gtk.IWidget
+	TimeLabel *gtk.Label
+	ReadLabel *gtk.Label
+
+	statusBox   *gtk.HBox
+	sendActions *gtk.HBox
+	sendState   api.SendState
 }
 
 func NewSentence(parent *ChatWindow, message *prot.Message) *Sentence {
 	sentence := &Sentence{Parent: parent, Message: message}
 	sentence.setupWidget()
+	sentence.setupStatus()
 	return sentence
 }
 
+func (self *Sentence) setupStatus() {
+	gray := gdk.NewColor("gray")
+	messageTime := goline.client.GetMessageTime(self.Message)
+	self.TimeLabel = gtk.NewLabel("")
+	self.TimeLabel.SetMarkup("<small>" + api.FormatMessageTime(messageTime) + "</small>")
+	self.TimeLabel.SetTooltipText(messageTime.Format("2006-01-02 15:04:05"))
+	self.TimeLabel.ModifyFG(gtk.STATE_NORMAL, gray)
+
+	self.statusBox = gtk.NewHBox(false, 5)
+	if self.Message.GetFrom() == goline.client.Profile.GetMid() {
+		self.ReadLabel = gtk.NewLabel("")
+		self.statusBox.PackEnd(self.TimeLabel, false, false, 0)
+		self.statusBox.PackEnd(self.ReadLabel, false, false, 0)
+	} else {
+		self.statusBox.PackStart(self.TimeLabel, false, false, 0)
+	}
+
+	table := gtk.NewTable(2, 1, false)
+	table.Attach(self.Widget, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	table.Attach(self.statusBox, 0, 1, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 0, 0)
+	self.Widget = table
+
+	state := api.SEND_SENT
+	if api.IsLocalMessageId(self.Message.GetId()) {
+		if pendingState, ok := self.Parent.Parent.Outbox.GetState(self.Message.GetId()); ok {
+			state = pendingState
+		}
+	}
+	self.SetSendState(state)
+}
+
+func (self *Sentence) SetSendState(state api.SendState) {
+	self.sendState = state
+	if self.ReadLabel == nil {
+		return
+	}
+	if state == api.SEND_FAILED {
+		self.ReadLabel.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
+		self.showSendActions()
+	} else {
+		self.ReadLabel.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("gray"))
+		self.hideSendActions()
+	}
+	self.UpdateReadCount(self.Parent.Parent.ReadReceipts.GetReadCount(
+		self.Parent.Entity.GetId(), self.Message.GetId()))
+}
+
+func (self *Sentence) Resolve(message *prot.Message) {
+	self.Message = message
+	messageTime := goline.client.GetMessageTime(message)
+	self.TimeLabel.SetMarkup("<small>" + api.FormatMessageTime(messageTime) + "</small>")
+	self.TimeLabel.SetTooltipText(messageTime.Format("2006-01-02 15:04:05"))
+	self.SetSendState(api.SEND_SENT)
+}
+
+func (self *Sentence) showSendActions() {
+	if self.sendActions != nil {
+		return
+	}
+	localId := self.Message.GetId()
+	resend := gtk.NewButtonWithLabel("Resend")
+	resend.Clicked(func() {
+		self.Parent.resendMessage(localId)
+	})
+	remove := gtk.NewButtonWithLabel("Delete")
+	remove.Clicked(func() {
+		self.Parent.deleteLocalMessage(localId)
+	})
+	self.sendActions = gtk.NewHBox(false, 2)
+	self.sendActions.PackStart(resend, false, false, 0)
+	self.sendActions.PackStart(remove, false, false, 0)
+	self.statusBox.PackEnd(self.sendActions, false, false, 0)
+	self.sendActions.ShowAll()
+}
+
+func (self *Sentence) hideSendActions() {
+	if self.sendActions == nil {
+		return
+	}
+	self.sendActions.Destroy()
+	self.sendActions = nil
+}
+
+func (self *Sentence) UpdateReadCount(count int) {
+	if self.ReadLabel == nil {
+		return
+	}
+	if self.sendState != api.SEND_SENT {
+		self.ReadLabel.SetMarkup("<small>" + self.sendState.String() + "</small>")
+		return
+	}
+	if count == 0 {
+		self.ReadLabel.SetText("")
+		return
+	}
+	text := "Read"
+	if _, ok := self.Parent.Entity.(*api.LineContactWrapper); !ok {
+		text = "Read by " + strconv.Itoa(count)
+	}
+	self.ReadLabel.SetMarkup("<small>" + text + "</small>")
+}
+
 func (self *Sentence) setupWidget() {
 	contentType := self.Message.GetContentType()
+	if placeholder, ok := self.Message.ContentMetadata[api.IMPORTED_PLACEHOLDER_KEY]; ok {
+		self.handleText(placeholder, gdk.NewColor("gray"))
+		return
+	}
 	//TODO: Support MIME type
-	switch contentType {
-	case prot.ContentType_NONE:
-		self.handleText(self.Message.GetText(), nil)
-	case prot.ContentType_IMAGE:
-		self.handleImage()
-	case prot.ContentType_VIDEO:
-		self.handleVideo()
-	case prot.ContentType_AUDIO:
-		self.handleAudio()
-	case prot.ContentType_STICKER:
-		self.handleSticker()
-	default:
-		self.handleText(contentType.String(), gdk.NewColor("red"))
+	if renderer, ok := sentenceRenderers[contentType]; ok {
+		renderer(self)
+		return
 	}
+	self.handleMetadata()
+}
+
+func (self *Sentence) handleLocation() {
+	location := self.Message.GetLocation()
+	if location == nil {
+		self.handleText("Location", gdk.NewColor("red"))
+		return
+	}
+
+	markup := "<b>" + html.EscapeString(location.GetTitle()) + "</b>"
+	if location.GetTitle() == "" {
+		markup = "<b>Location</b>"
+	}
+	if location.GetAddress() != "" {
+		markup += "\n" + html.EscapeString(location.GetAddress())
+	}
+	markup += "\n<small>" + api.FormatCoordinates(location) + "</small>"
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
+	label.SetAlignment(0, 0.5)
+	label.SetLineWrap(true)
+	label.SetSelectable(true)
+
+	copyCoordinates := gtk.NewButtonWithLabel("Copy Coordinates")
+	copyCoordinates.Clicked(func() {
+		CopyToClipboard(api.FormatCoordinates(location))
+	})
+	openMap := gtk.NewButtonWithLabel("Open Map")
+	openMap.Clicked(func() {
+		OpenUri(api.GetOpenStreetMapUrl(location))
+	})
+	openGeo := gtk.NewButtonWithLabel("Open in App")
+	openGeo.Clicked(func() {
+		OpenUri(api.GetGeoUri(location))
+	})
+
+	table := gtk.NewTable(2, 3, false)
+	table.Attach(label, 0, 3, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	table.Attach(copyCoordinates, 0, 1, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+	table.Attach(openMap, 1, 2, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+	table.Attach(openGeo, 2, 3, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+
+	frame := gtk.NewFrame("")
+	frame.Add(table)
+	self.Widget = self.tableLayout(frame)
+}
+
+func (self *Sentence) handleFile() {
+	fileName := api.GetMessageFileName(self.Message)
+	details := api.GetFileType(fileName)
+	if size, ok := api.GetFileSize(self.Message); ok {
+		details += ", " + formatBytes(size)
+	}
+	expired := api.IsFileExpired(self.Message)
+	if expireTime, ok := api.GetFileExpireTime(self.Message); ok {
+		if expired {
+			details += "\nExpired on " + expireTime.Local().Format("2006-01-02 15:04")
+		} else {
+			details += "\nAvailable until " + expireTime.Local().Format("2006-01-02 15:04")
+		}
+	}
+
+	markup := "<b>" + html.EscapeString(fileName) + "</b>\n<small>" + html.EscapeString(details) + "</small>"
+	if expired {
+		markup = "<span foreground=\"gray\">" + markup + "</span>"
+	}
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
+	label.SetAlignment(0, 0.5)
+	label.SetLineWrap(true)
+	label.SetSelectable(true)
+
+	download := gtk.NewButtonWithLabel("Download")
+	if expired {
+		download.SetLabel("Expired")
+		download.SetSensitive(false)
+	}
+	download.Clicked(func() {
+		if api.IsFileExpired(self.Message) {
+			download.SetLabel("Expired")
+			download.SetSensitive(false)
+			RunAlertMessage(self.Parent.Window, "This file has expired.")
+			return
+		}
+		go self.showDownloadWindow(api.GetFileDownloadUrl(self.Message))
+	})
+
+	table := gtk.NewTable(2, 1, false)
+	table.Attach(label, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	table.Attach(download, 0, 1, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+
+	frame := gtk.NewFrame("")
+	frame.Add(table)
+	self.Widget = self.tableLayout(frame)
+}
+
+func (self *Sentence) handleContact() {
+	mid, displayName := api.GetSharedContact(self.Message)
+	if mid == "" {
+		self.handleText("Contact", gdk.NewColor("red"))
+		return
+	}
+	if displayName == "" {
+		displayName = "Unknown"
+	}
+
+	avatar := gtk.NewImage()
+	avatar.SetSizeRequest(48, 48)
+	label := gtk.NewLabel("")
+	label.SetMarkup("<b>" + html.EscapeString(displayName) + "</b>")
+	label.SetAlignment(0, 0.5)
+
+	ownId := goline.client.Profile.GetMid()
+	addFriend := gtk.NewButtonWithLabel("Add Friend")
+	if mid == ownId || goline.client.GetContactById(mid) != nil {
+		addFriend.SetSensitive(false)
+	}
+	addFriend.Clicked(func() {
+		addFriend.SetSensitive(false)
+		go self.addSharedContact(mid, addFriend)
+	})
+	openChat := gtk.NewButtonWithLabel("Open Chat")
+	openChat.SetSensitive(mid != ownId)
+	openChat.Clicked(func() {
+		self.Parent.Parent.openChat(mid)
+	})
+
+	table := gtk.NewTable(2, 3, false)
+	table.Attach(avatar, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 5, 5)
+	table.Attach(label, 1, 3, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	table.Attach(addFriend, 0, 2, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+	table.Attach(openChat, 2, 3, 1, 2, gtk.FILL, gtk.FILL, 2, 2)
+
+	frame := gtk.NewFrame("")
+	frame.Add(table)
+	self.Widget = self.tableLayout(frame)
+
+	chatWindow := self.Parent
+	conversation := chatWindow.Conversation
+	go func() {
+		contact, err := goline.client.FetchContact(mid)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+		LoadAvatar(avatar, api.NewLineContactWrapper(contact), 48, func() bool {
+			return chatWindow.Parent.ChatWindows[chatWindow.Entity.GetId()] == chatWindow &&
+				chatWindow.Conversation == conversation
+		})
+	}()
+}
+
+func (self *Sentence) addSharedContact(mid string, button *gtk.Button) {
+	_, err := goline.client.FindAndAddContactsByMid(mid)
+	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
+	if err != nil {
+		goline.LoggerPrintln(err)
+		RunErrorMessage(self.Parent.Window, "Failed to add friend.")
+		button.SetSensitive(true)
+		return
+	}
+	button.SetLabel("Added")
+	self.Parent.Parent.rebuildFriendsTable()
 }
 
 func (self *Sentence) handleSticker() {
@@ -48,16 +321,11 @@ func (self *Sentence) handleSticker() {
 	stkpkgid := meta["STKPKGID"]
 	stkver := meta["STKVER"]
 	url := api.LINE_STICKER_URL + stkver + "/" + stkpkgid + "/PC/stickers/" + stkid + ".png"
-	filePath := path.Join(goline.TempDirPath, "sticker", stkid+".png")
-	if CheckFileNotExist(filePath) {
-		err := DownloadFile(url, filePath)
-		if err != nil {
-			goline.LoggerPrintln(err)
-			self.handleText("Failed to download sticker.", gdk.NewColor("red"))
-			return
-
-		}
-
+	filePath, err := goline.cache.Get(api.CACHE_STICKER, stkid+".png", url)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.handleText("Failed to download sticker.", gdk.NewColor("red"))
+		return
 	}
 	image := gtk.NewImageFromFile(filePath)
 	self.Widget = self.tableLayout(image)
@@ -81,13 +349,30 @@ func (self *Sentence) tableLayout(widget gtk.IWidget) gtk.IWidget {
 		label.ModifyFG(gtk.STATE_NORMAL, self.NewRandomColorFromId(fromId))
 		label.SetAlignment(0, 0)
 
-		nameTable := gtk.NewTable(2, 1, false)
-		nameTable.Attach(label, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 0, 3)
-		nameTable.Attach(table, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 0, 3)
+		nameTable := gtk.NewTable(3, 1, false)
+		nameTable.Attach(self.newAvatar(fromId), 0, 1, 0, 1, gtk.FILL, gtk.FILL, 3, 3)
+		nameTable.Attach(label, 1, 2, 0, 1, gtk.FILL, gtk.FILL, 0, 3)
+		nameTable.Attach(table, 2, 3, 0, 1, gtk.FILL, gtk.FILL, 0, 3)
 		return nameTable
 	}
 }
 
+func (self *Sentence) newAvatar(fromId string) gtk.IWidget {
+	avatar := gtk.NewImage()
+	avatar.SetSizeRequest(32, 32)
+	contact := goline.client.GetContactById(fromId)
+	if contact == nil {
+		return avatar
+	}
+	chatWindow := self.Parent
+	conversation := chatWindow.Conversation
+	LoadAvatar(avatar, api.NewLineContactWrapper(contact), 32, func() bool {
+		return chatWindow.Parent.ChatWindows[chatWindow.Entity.GetId()] == chatWindow &&
+			chatWindow.Conversation == conversation
+	})
+	return avatar
+}
+
 func (self *Sentence) handleAudio() {
 	messageId := self.Message.GetId()
 	label := gtk.NewLabel("Download Audio")
@@ -103,15 +388,12 @@ func (self *Sentence) handleAudio() {
 
 func (self *Sentence) handleVideo() {
 	messageId := self.Message.GetId()
-	previewFilePath := path.Join(goline.TempDirPath, "preview", messageId)
-	if CheckFileNotExist(previewFilePath) {
-		previewUrl := api.LINE_OBJECT_STORAGE_URL + messageId + "/preview"
-		err := DownloadFile(previewUrl, previewFilePath)
-		if err != nil {
-			goline.LoggerPrintln(err)
-			self.handleText("Failed to download video preview.", gdk.NewColor("red"))
-			return
-		}
+	previewUrl := api.LINE_OBJECT_STORAGE_URL + messageId + "/preview"
+	previewFilePath, err := goline.cache.Get(api.CACHE_PREVIEW, messageId, previewUrl)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.handleText("Failed to download video preview.", gdk.NewColor("red"))
+		return
 	}
 
 	image := gtk.NewImageFromFile(previewFilePath)
@@ -139,42 +421,28 @@ func (self *Sentence) handleVideo() {
 
 func (self *Sentence) showDownloadWindow(url string) {
 	gdk.ThreadsEnter()
+	defer gdk.ThreadsLeave()
 	dialog := gtk.NewFileChooserDialog("Save File",
 		self.Parent.Window,
 		gtk.FILE_CHOOSER_ACTION_SAVE,
 		gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL,
 		gtk.STOCK_SAVE, gtk.RESPONSE_ACCEPT)
-
+	dialog.SetDoOverwriteConfirmation(true)
+	dialog.SetCurrentName(api.GetMessageFileName(self.Message))
 	res := dialog.Run()
-	if res == gtk.RESPONSE_ACCEPT {
-		w := gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
-		filePath := dialog.GetFilename()
-		w.SetTitle("Download Status")
-		w.SetPosition(gtk.WIN_POS_MOUSE)
-		w.SetDefaultSize(250, 150)
-		label := gtk.NewLabel("Downloading...")
-		w.Add(label)
-		dialog.Destroy()
-		w.ShowAll()
-		gdk.ThreadsLeave()
-		err := DownloadFile(url, filePath)
-		gdk.ThreadsEnter()
-		if err != nil {
-			goline.LoggerPrintln(err)
-			label.SetText("Download failed.")
-			label.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
-		} else {
-			label.SetText("Download successed.")
-			label.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("blue"))
-		}
-		gdk.ThreadsLeave()
-	} else {
-		dialog.Destroy()
-		gdk.ThreadsLeave()
+	filePath := dialog.GetFilename()
+	dialog.Destroy()
+	if res != gtk.RESPONSE_ACCEPT {
+		return
 	}
+	goline.downloads.Add(url, filePath)
+	self.Parent.Parent.showDownloadsWindow()
 }
 
 func (self *Sentence) getNameById(id string) string {
+	if strings.HasPrefix(id, api.IMPORTED_MID_PREFIX) {
+		return self.Message.ContentMetadata[api.IMPORTED_SENDER_META]
+	}
 	entity, err := goline.client.GetLineEntityById(id)
 	if err != nil || entity == nil {
 		return "Unknown"
@@ -200,16 +468,14 @@ func (self *Sentence) showImageWindow(id string) {
 	w.ShowAll()
 	gdk.ThreadsLeave()
 
-	filePath := path.Join(goline.TempDirPath, "image", id)
-	if CheckFileNotExist(filePath) {
-		url := api.LINE_OBJECT_STORAGE_URL + id
-		err := DownloadFile(url, filePath)
-		if err != nil {
-			goline.LoggerPrintln(err)
-			label.SetText("Failed to download image.")
-			label.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
-			return
-		}
+	filePath, err := goline.cache.Get(api.CACHE_IMAGE, id, api.LINE_OBJECT_STORAGE_URL+id)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		gdk.ThreadsEnter()
+		label.SetText("Failed to download image.")
+		label.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor("red"))
+		gdk.ThreadsLeave()
+		return
 	}
 	gdk.ThreadsEnter()
 	image := gtk.NewImageFromFile(filePath)
@@ -222,22 +488,18 @@ func (self *Sentence) showImageWindow(id string) {
 
 func (self *Sentence) handleImage() {
 	messageId := self.Message.GetId()
-	previewFilePath := path.Join(goline.TempDirPath, "preview", messageId)
 	meta := self.Message.ContentMetadata
-	if CheckFileNotExist(previewFilePath) {
-		var previewUrl string
-		if meta["PUBLIC"] == "TRUE" {
-			previewUrl = meta["PREVIEW_URL"]
-		} else {
-
-			previewUrl = api.LINE_OBJECT_STORAGE_URL + messageId + "/preview"
-		}
-		err := DownloadFile(previewUrl, previewFilePath)
-		if err != nil {
-			goline.LoggerPrintln(err)
-			self.handleText("Failed to download image preview.", gdk.NewColor("red"))
-			return
-		}
+	var previewUrl string
+	if meta["PUBLIC"] == "TRUE" {
+		previewUrl = meta["PREVIEW_URL"]
+	} else {
+		previewUrl = api.LINE_OBJECT_STORAGE_URL + messageId + "/preview"
+	}
+	previewFilePath, err := goline.cache.Get(api.CACHE_PREVIEW, messageId, previewUrl)
+	if err != nil {
+		goline.LoggerPrintln(err)
+		self.handleText("Failed to download image preview.", gdk.NewColor("red"))
+		return
 	}
 
 	image := gtk.NewImageFromFile(previewFilePath)
@@ -290,21 +552,89 @@ func (self *Sentence) handleText(text string, color *gdk.Color) {
 	fromId := self.Message.GetFrom()
 	var label *gtk.Label
 	if fromId == goline.client.Profile.GetMid() {
-		label = gtk.NewLabel(text)
+		label = newTextLabel(api.FormatTextMarkup(text))
 		label.SetAlignment(1, 0.5)
 	} else {
 		if color == nil {
 			color = self.NewRandomColorFromId(fromId)
 		}
 		name := self.getNameById(fromId)
-		label = gtk.NewLabel(name + ": " + text)
+		label = newTextLabel(html.EscapeString(name+": ") + api.FormatTextMarkup(text))
 		label.SetAlignment(0, 0.5)
 	}
 	if color != nil {
 		label.ModifyFG(gtk.STATE_NORMAL, color)
 	}
+	self.Widget = label
+}
+
+func (self *Sentence) handleTextMessage() {
+	segments := api.SplitEmoticons(self.Message)
+	if len(api.GetEmoticons(self.Message)) == 0 || len(segments) == 0 {
+		self.handleText(self.Message.GetText(), nil)
+		return
+	}
+	fromId := self.Message.GetFrom()
+	isOwn := fromId == goline.client.Profile.GetMid()
+	color := self.NewRandomColorFromId(fromId)
+
+	box := gtk.NewHBox(false, 0)
+	if !isOwn {
+		label := gtk.NewLabel(self.getNameById(fromId) + ": ")
+		label.ModifyFG(gtk.STATE_NORMAL, color)
+		box.PackStart(label, false, false, 0)
+	}
+	for _, segment := range segments {
+		if segment.Emoticon == nil {
+			label := newTextLabel(api.FormatTextMarkup(segment.Text))
+			if !isOwn {
+				label.ModifyFG(gtk.STATE_NORMAL, color)
+			}
+			box.PackStart(label, false, false, 0)
+			continue
+		}
+		image := gtk.NewImage()
+		image.SetSizeRequest(EMOTICON_SIZE, EMOTICON_SIZE)
+		box.PackStart(image, false, false, 0)
+		self.loadEmoticon(image, segment.Emoticon)
+	}
+	if isOwn {
+		alignment := gtk.NewAlignment(1, 0.5, 0, 0)
+		alignment.Add(box)
+		self.Widget = alignment
+		return
+	}
+	self.Widget = box
+}
+
+func (self *Sentence) loadEmoticon(image *gtk.Image, emoticon *api.Emoticon) {
+	chatWindow := self.Parent
+	conversation := chatWindow.Conversation
+	name := "sticon_" + emoticon.ProductId + "_" + emoticon.SticonId
+	go func() {
+		filePath, err := goline.cache.Get(api.CACHE_STICKER, name, api.GetEmoticonUrl(emoticon))
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if chatWindow.Parent.ChatWindows[chatWindow.Entity.GetId()] == chatWindow &&
+			chatWindow.Conversation == conversation {
+			SetImageFromFileAtScale(image, filePath, EMOTICON_SIZE)
+		}
+	}()
+}
+
+func newTextLabel(markup string) *gtk.Label {
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
 	label.SetLineWrap(true)
 	label.SetUseLineWrapMode(pango.WRAP_CHAR)
 	label.SetSelectable(true)
-	self.Widget = label
+	label.Connect("activate-link", func(ctx *glib.CallbackContext) bool {
+		OpenUri(ctx.Args(0).ToString())
+		return true
+	})
+	return label
 }
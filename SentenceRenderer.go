@@ -0,0 +1,130 @@
+package main
+
+import (
+	"html"
+
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+const RICH_THUMBNAIL_SIZE = 96
+
+type SentenceRenderer func(sentence *Sentence)
+
+var sentenceRenderers = map[prot.ContentType]SentenceRenderer{}
+
+func RegisterSentenceRenderer(contentType prot.ContentType, renderer SentenceRenderer) {
+	sentenceRenderers[contentType] = renderer
+}
+
+func init() {
+	RegisterSentenceRenderer(prot.ContentType_NONE, (*Sentence).handleTextMessage)
+	RegisterSentenceRenderer(prot.ContentType_IMAGE, (*Sentence).handleImage)
+	RegisterSentenceRenderer(prot.ContentType_VIDEO, (*Sentence).handleVideo)
+	RegisterSentenceRenderer(prot.ContentType_AUDIO, (*Sentence).handleAudio)
+	RegisterSentenceRenderer(prot.ContentType_STICKER, (*Sentence).handleSticker)
+	RegisterSentenceRenderer(prot.ContentType_LOCATION, (*Sentence).handleLocation)
+	RegisterSentenceRenderer(prot.ContentType_CONTACT, (*Sentence).handleContact)
+	RegisterSentenceRenderer(prot.ContentType_FILE, (*Sentence).handleFile)
+	for _, contentType := range []prot.ContentType{
+		prot.ContentType_LINK,
+		prot.ContentType_HTML,
+		prot.ContentType_RICH,
+		prot.ContentType_APPLINK,
+		prot.ContentType_GROUPBOARD,
+		prot.ContentType_POSTNOTIFICATION,
+	} {
+		RegisterSentenceRenderer(contentType, (*Sentence).handleRich)
+	}
+}
+
+func (self *Sentence) handleRich() {
+	content := api.GetRichContent(self.Message)
+	if content.Title == "" && content.ThumbnailUrl == "" {
+		self.handleMetadata()
+		return
+	}
+
+	markup := "<b>" + html.EscapeString(content.Title) + "</b>"
+	if content.Description != "" {
+		markup += "\n" + html.EscapeString(content.Description)
+	}
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
+	label.SetAlignment(0, 0)
+	label.SetLineWrap(true)
+	label.SetSelectable(true)
+
+	table := gtk.NewTable(2, 2, false)
+	table.Attach(label, 1, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+
+	if api.IsWebUrl(content.ThumbnailUrl) {
+		thumbnail := gtk.NewImage()
+		table.Attach(thumbnail, 0, 1, 0, 1, gtk.FILL, gtk.FILL, 5, 5)
+		self.loadThumbnail(thumbnail, content.ThumbnailUrl)
+	}
+
+	if content.Url != "" {
+		url := content.Url
+		link := gtk.NewLabel("")
+		link.SetAlignment(0, 0.5)
+		link.SetLineWrap(true)
+		box := gtk.NewEventBox()
+		box.Add(link)
+		if api.IsWebUrl(url) {
+			link.SetMarkup("<small><u>" + html.EscapeString(url) + "</u></small>")
+			box.SetEvents(int(gdk.BUTTON_RELEASE_MASK))
+			box.Connect("button-release-event", func() {
+				OpenUri(url)
+			})
+		} else {
+			link.SetMarkup("<small>" + html.EscapeString(url) + "</small>")
+		}
+		table.Attach(box, 0, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	}
+
+	frame := gtk.NewFrame(self.Message.GetContentType().String())
+	frame.Add(table)
+	self.Widget = self.tableLayout(frame)
+}
+
+func (self *Sentence) handleMetadata() {
+	meta := self.Message.GetContentMetadata()
+	markup := "<b>" + html.EscapeString(self.Message.GetContentType().String()) + "</b>"
+	if text := self.Message.GetText(); text != "" {
+		markup += "\n" + html.EscapeString(text)
+	}
+	if len(meta) > 0 {
+		markup += "\n<small>" + html.EscapeString(api.FormatMetadata(meta)) + "</small>"
+	}
+	label := gtk.NewLabel("")
+	label.SetMarkup(markup)
+	label.SetAlignment(0, 0.5)
+	label.SetLineWrap(true)
+	label.SetSelectable(true)
+
+	frame := gtk.NewFrame("")
+	frame.Add(label)
+	self.Widget = self.tableLayout(frame)
+}
+
+func (self *Sentence) loadThumbnail(image *gtk.Image, url string) {
+	chatWindow := self.Parent
+	conversation := chatWindow.Conversation
+	name := self.Message.GetId()
+	go func() {
+		filePath, err := goline.cache.GetWith(api.CACHE_THUMBNAIL, name, url, DownloadPublicFile)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if chatWindow.Parent.ChatWindows[chatWindow.Entity.GetId()] == chatWindow &&
+			chatWindow.Conversation == conversation {
+			SetImageFromFileAtScale(image, filePath, RICH_THUMBNAIL_SIZE)
+		}
+	}()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
+	"time"
+
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+type muteOption struct {
+	Name     string
+	Duration time.Duration
+}
+
+var muteOptions = []muteOption{
+	{"Keep current", -1},
+	{"Not muted", 0},
+	{"1 hour", time.Hour},
+	{"8 hours", 8 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+}
+
+type SettingsWindow struct {
+	Parent *MainWindow
+	Window *gtk.Window
+
+	Table      *gtk.Table
+	Checks     map[prot.SettingsAttribute]*gtk.CheckButton
+	MuteStatus *gtk.Label
+	Mute       *gtk.ComboBoxText
+	Status     *gtk.Label
+	Save       *gtk.Button
+	Close      *gtk.Button
+
+	settings *prot.Settings
+}
+
+func NewSettingsWindow(parent *MainWindow) *SettingsWindow {
+	settingsWindow := &SettingsWindow{Parent: parent}
+	settingsWindow.Checks = make(map[prot.SettingsAttribute]*gtk.CheckButton)
+	settingsWindow.setupUI()
+	settingsWindow.load()
+	return settingsWindow
+}
+
+func (self *SettingsWindow) newSettingsFrame(title string, items []*api.SettingsItem) *gtk.Frame {
+	table := gtk.NewTable(uint(len(items)), 1, false)
+	for idx, item := range items {
+		check := gtk.NewCheckButtonWithLabel(item.Name)
+		table.Attach(check, 0, 1, uint(idx), uint(idx+1), gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 0)
+		self.Checks[item.Attribute] = check
+	}
+	frame := gtk.NewFrame(title)
+	frame.Add(table)
+	return frame
+}
+
+func (self *SettingsWindow) setupUI() {
+	self.Window = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	self.Window.SetTitle("Settings")
+	self.Window.SetTransientFor(self.Parent.Window)
+	self.Window.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	self.Window.Connect("destroy", func() {
+		self.Parent.SettingsWindow = nil
+	})
+
+	notification := self.newSettingsFrame("Notifications", api.NotificationSettings)
+	privacy := self.newSettingsFrame("Privacy", api.PrivacySettings)
+
+	self.MuteStatus = gtk.NewLabel("")
+	self.MuteStatus.SetAlignment(0, 0.5)
+	self.Mute = gtk.NewComboBoxText()
+	for _, option := range muteOptions {
+		self.Mute.AppendText(option.Name)
+	}
+	self.Mute.SetActive(0)
+	muteTable := gtk.NewTable(2, 1, false)
+	muteTable.Attach(self.MuteStatus, 0, 1, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	muteTable.Attach(self.Mute, 0, 1, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 3, 3)
+	mute := gtk.NewFrame("Mute Notifications")
+	mute.Add(muteTable)
+
+	self.Status = gtk.NewLabel("Loading...")
+	self.Status.SetAlignment(0, 0.5)
+
+	self.Save = gtk.NewButtonWithLabel("Save")
+	self.Save.SetSensitive(false)
+	self.Save.Clicked(self.save)
+	self.Close = gtk.NewButtonWithLabel("Close")
+	self.Close.Clicked(func() {
+		self.Window.Destroy()
+	})
+
+	self.Table = gtk.NewTable(5, 2, false)
+	self.Table.Attach(notification, 0, 2, 0, 1, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(mute, 0, 2, 1, 2, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(privacy, 0, 2, 2, 3, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Status, 0, 2, 3, 4, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Save, 0, 1, 4, 5, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Table.Attach(self.Close, 1, 2, 4, 5, gtk.EXPAND|gtk.FILL, gtk.FILL, 5, 5)
+	self.Window.Add(self.Table)
+}
+
+func (self *SettingsWindow) setStatus(text string, color string) {
+	self.Status.SetText(text)
+	self.Status.ModifyFG(gtk.STATE_NORMAL, gdk.NewColor(color))
+}
+
+func (self *SettingsWindow) load() {
+	go func() {
+		settings, err := goline.client.RefreshSettings()
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		if err != nil {
+			goline.LoggerPrintln(err)
+			self.setStatus("Failed to load settings.", "red")
+			return
+		}
+		self.Refresh(settings)
+		self.setStatus("", "blue")
+	}()
+}
+
+func (self *SettingsWindow) Refresh(settings *prot.Settings) {
+	if settings == nil {
+		return
+	}
+	for _, items := range [][]*api.SettingsItem{api.NotificationSettings, api.PrivacySettings} {
+		for _, item := range items {
+			check := self.Checks[item.Attribute]
+			if self.settings != nil && check.GetActive() != item.Get(self.settings) {
+				continue
+			}
+			check.SetActive(item.Get(settings))
+		}
+	}
+	copied := *settings
+	self.settings = &copied
+	expiration := settings.GetNotificationMuteExpiration()
+	muteUntil := time.Unix(0, expiration*int64(time.Millisecond))
+	if expiration > 0 && muteUntil.After(time.Now()) {
+		self.MuteStatus.SetText("Muted until " + muteUntil.Local().Format("2006/01/02 15:04"))
+	} else {
+		self.MuteStatus.SetText("Not muted")
+	}
+	self.Save.SetSensitive(true)
+}
+
+func (self *SettingsWindow) save() {
+	if self.settings == nil {
+		return
+	}
+	settings := *self.settings
+	var attrs prot.SettingsAttribute
+	for _, items := range [][]*api.SettingsItem{api.NotificationSettings, api.PrivacySettings} {
+		for _, item := range items {
+			value := self.Checks[item.Attribute].GetActive()
+			if value != item.Get(&settings) {
+				item.Set(&settings, value)
+				attrs |= item.Attribute
+			}
+		}
+	}
+	option := muteOptions[self.Mute.GetActive()]
+	if attrs == 0 && option.Duration < 0 {
+		self.setStatus("Nothing to save.", "blue")
+		return
+	}
+
+	self.Save.SetSensitive(false)
+	self.setStatus("Saving...", "blue")
+	go func() {
+		var updated *prot.Settings
+		var err error
+		if attrs != 0 {
+			updated, err = goline.client.UpdateSettingsAttributes(attrs, &settings)
+		}
+		if err == nil && option.Duration >= 0 {
+			var expiration int64
+			if option.Duration > 0 {
+				expiration = time.Now().Add(option.Duration).UnixNano() / int64(time.Millisecond)
+			}
+			updated, err = goline.client.SetNotificationMuteExpiration(expiration)
+		}
+		gdk.ThreadsEnter()
+		defer gdk.ThreadsLeave()
+		self.Save.SetSensitive(true)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			self.setStatus("Failed to save settings.", "red")
+			return
+		}
+		self.Mute.SetActive(0)
+		self.Refresh(updated)
+		self.setStatus("Settings saved.", "blue")
+	}()
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const AVATAR_DOWNLOAD_LIMIT = 4
+
+type AvatarCache struct {
+	DirPath  string
+	Download func(url, filePath string) error
+	Cache    *MediaCache
+
+	inflight map[string]chan struct{}
+	slots    chan struct{}
+	lock     sync.Mutex
+}
+
+func NewAvatarCache(dirPath string, download func(url, filePath string) error) (*AvatarCache, error) {
+	err := os.MkdirAll(dirPath, os.FileMode(0700))
+	if err != nil {
+		return nil, err
+	}
+	return &AvatarCache{
+		DirPath:  dirPath,
+		Download: download,
+		inflight: make(map[string]chan struct{}),
+		slots:    make(chan struct{}, AVATAR_DOWNLOAD_LIMIT)}, nil
+}
+
+func GetProfilePictureUrl(pictureStatus string) string {
+	if pictureStatus == "" {
+		return ""
+	}
+	return LINE_PROFILE_URL + "/" + pictureStatus + "/preview"
+}
+
+func GetContactPicture(contact *prot.Contact) (status string, url string) {
+	status = contact.GetPictureStatus()
+	thumbnailUrl := contact.GetThumbnailUrl()
+	switch {
+	case strings.HasPrefix(thumbnailUrl, "http"):
+		url = thumbnailUrl
+	case thumbnailUrl != "":
+		url = LINE_PROFILE_URL + thumbnailUrl
+	default:
+		url = GetProfilePictureUrl(status)
+	}
+	if status == "" && url != "" {
+		status = thumbnailUrl
+	}
+	return
+}
+
+func GetEntityPicture(entity LineEntity) (status string, url string) {
+	switch wrapper := entity.(type) {
+	case *LineContactWrapper:
+		return GetContactPicture(wrapper.GetContact())
+	case *LineGroupWrapper:
+		status = wrapper.GetGroup().GetPictureStatus()
+		return status, GetProfilePictureUrl(status)
+	case *LineRoomWrapper:
+		for _, contact := range wrapper.GetRoom().GetContacts() {
+			status, url = GetContactPicture(contact)
+			if url != "" {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (self *AvatarCache) getPrefix(id string) string {
+	return path.Join(self.DirPath, id+"_")
+}
+
+func (self *AvatarCache) getFilePath(id string, status string) string {
+	sum := md5.Sum([]byte(status))
+	return self.getPrefix(id) + hex.EncodeToString(sum[:8])
+}
+
+func (self *AvatarCache) removeStale(id string, keep string) {
+	matches, err := filepath.Glob(self.getPrefix(id) + "*")
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		if !strings.HasPrefix(match, keep) {
+			os.Remove(match)
+		}
+	}
+}
+
+func (self *AvatarCache) acquire(key string) {
+	for {
+		self.lock.Lock()
+		wait, busy := self.inflight[key]
+		if !busy {
+			self.inflight[key] = make(chan struct{})
+			self.lock.Unlock()
+			return
+		}
+		self.lock.Unlock()
+		<-wait
+	}
+}
+
+func (self *AvatarCache) release(key string) {
+	self.lock.Lock()
+	close(self.inflight[key])
+	delete(self.inflight, key)
+	self.lock.Unlock()
+}
+
+func (self *AvatarCache) Get(id string, status string, url string) (string, error) {
+	if status == "" || url == "" {
+		return "", nil
+	}
+	filePath := self.getFilePath(id, status)
+	if _, err := os.Stat(filePath); err == nil {
+		Touch(filePath)
+		return filePath, nil
+	}
+
+	self.acquire(id)
+	defer self.release(id)
+	if _, err := os.Stat(filePath); err == nil {
+		return filePath, nil
+	}
+	self.removeStale(id, filePath)
+
+	self.slots <- struct{}{}
+	defer func() { <-self.slots }()
+	tmpFilePath := filePath + ".tmp"
+	err := self.Download(url, tmpFilePath)
+	if err == nil {
+		err = CheckCacheFile(tmpFilePath)
+	}
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return "", err
+	}
+	err = os.Rename(tmpFilePath, filePath)
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return "", err
+	}
+	self.added(filePath)
+	return filePath, nil
+}
+
+func (self *AvatarCache) added(filePath string) {
+	if self.Cache != nil {
+		self.Cache.Added(filePath)
+	}
+}
+
+func (self *AvatarCache) GetRound(id string, status string, url string, size int) (string, error) {
+	filePath, err := self.Get(id, status, url)
+	if err != nil || filePath == "" {
+		return "", err
+	}
+	roundFilePath := filePath + "_" + strconv.Itoa(size) + ".png"
+	if _, err := os.Stat(roundFilePath); err == nil {
+		Touch(roundFilePath)
+		return roundFilePath, nil
+	}
+	err = writeRoundImage(filePath, roundFilePath, size)
+	if err != nil {
+		return "", err
+	}
+	self.added(roundFilePath)
+	return roundFilePath, nil
+}
+
+func (self *AvatarCache) GetEntity(entity LineEntity, size int) (string, error) {
+	status, url := GetEntityPicture(entity)
+	return self.GetRound(entity.GetId(), status, url, size)
+}
+
+func (self *AvatarCache) Invalidate(id string) {
+	self.removeStale(id, "\x00")
+}
+
+func writeRoundImage(srcFilePath string, dstFilePath string, size int) error {
+	src, err := os.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if side == 0 || size <= 0 {
+		return errors.New("Invalid avatar size.")
+	}
+	left := bounds.Min.X + (bounds.Dx()-side)/2
+	top := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	radius := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := float64(x) + 0.5 - radius
+			dy := float64(y) + 0.5 - radius
+			coverage := radius - math.Sqrt(dx*dx+dy*dy) + 0.5
+			if coverage <= 0 {
+				continue
+			}
+			if coverage > 1 {
+				coverage = 1
+			}
+			c := averageColor(img,
+				left+x*side/size, top+y*side/size,
+				left+(x+1)*side/size, top+(y+1)*side/size)
+			c.A = uint8(float64(c.A) * coverage)
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+
+	tmpFilePath := dstFilePath + ".tmp"
+	dstFile, err := os.Create(tmpFilePath)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(dstFile, dst)
+	if err != nil {
+		dstFile.Close()
+		os.Remove(tmpFilePath)
+		return err
+	}
+	err = dstFile.Close()
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return err
+	}
+	return os.Rename(tmpFilePath, dstFilePath)
+}
+
+func averageColor(img image.Image, x0, y0, x1, y1 int) color.NRGBA {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var r, g, b, a, n uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			r += uint64(c.R)
+			g += uint64(c.G)
+			b += uint64(c.B)
+			a += uint64(c.A)
+			n += 1
+		}
+	}
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"sort"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func putContactInto(contacts ContactSlice, contact *prot.Contact) ContactSlice {
+	for _, existing := range contacts {
+		if existing.GetMid() == contact.GetMid() {
+			return contacts
+		}
+	}
+	contacts = append(contacts, contact)
+	sort.Sort(contacts)
+	return contacts
+}
+
+func removeContactFrom(contacts ContactSlice, mid string) ContactSlice {
+	for idx, contact := range contacts {
+		if contact.GetMid() == mid {
+			return append(contacts[:idx], contacts[idx+1:]...)
+		}
+	}
+	return contacts
+}
+
+func (self *LineClient) getContactForList(mid string) (*prot.Contact, error) {
+	contact := self.GetContactById(mid)
+	if contact != nil {
+		return contact, nil
+	}
+	contacts, err := self.client.GetContacts([]string{mid})
+	if err != nil {
+		return nil, err
+	}
+	if len(contacts) == 0 {
+		return &prot.Contact{Mid: mid}, nil
+	}
+	return contacts[0], nil
+}
+
+func (self *LineClient) RefreshBlockedContacts() ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ids, err := self.client.GetBlockedContactIds()
+	if err != nil {
+		return nil, err
+	}
+	self.Blocked, err = self.client.GetContacts(ids)
+	sort.Sort(self.Blocked)
+	return self.Blocked, err
+}
+
+func (self *LineClient) RefreshHiddenContacts() ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ids, err := self.client.GetHiddenContactMids()
+	if err != nil {
+		return nil, err
+	}
+	self.Hidden, err = self.client.GetContacts(ids)
+	sort.Sort(self.Hidden)
+	return self.Hidden, err
+}
+
+func (self *LineClient) IsContactBlocked(mid string) bool {
+	for _, contact := range self.Blocked {
+		if contact.GetMid() == mid {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *LineClient) IsContactHidden(mid string) bool {
+	for _, contact := range self.Hidden {
+		if contact.GetMid() == mid {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *LineClient) BlockContact(mid string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.BlockContact(0, mid)
+	if err != nil {
+		return err
+	}
+	contact, err := self.getContactForList(mid)
+	if err != nil {
+		return err
+	}
+	self.Blocked = putContactInto(self.Blocked, contact)
+	return nil
+}
+
+func (self *LineClient) UnblockContact(mid string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.UnblockContact(0, mid)
+	if err != nil {
+		return err
+	}
+	self.Blocked = removeContactFrom(self.Blocked, mid)
+	return nil
+}
+
+func (self *LineClient) HideContact(mid string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.UpdateContactSetting(0, mid,
+		prot.ContactSetting_CONTACT_SETTING_CONTACT_HIDE, "True")
+	if err != nil {
+		return err
+	}
+	contact, err := self.getContactForList(mid)
+	if err != nil {
+		return err
+	}
+	self.Hidden = putContactInto(self.Hidden, contact)
+	return nil
+}
+
+func (self *LineClient) UnhideContact(mid string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.UpdateContactSetting(0, mid,
+		prot.ContactSetting_CONTACT_SETTING_CONTACT_HIDE, "False")
+	if err != nil {
+		return err
+	}
+	self.Hidden = removeContactFrom(self.Hidden, mid)
+	return nil
+}
+
+func (self *LineClient) IsBlockedOperation(operation *prot.Operation) bool {
+	switch operation.GetTypeA1() {
+	case prot.OpType_RECEIVE_MESSAGE:
+		message := operation.GetMessage()
+		return message != nil && self.IsContactBlocked(message.GetFrom())
+	case prot.OpType_NOTIFIED_INVITE_INTO_ROOM:
+		fallthrough
+	case prot.OpType_NOTIFIED_INVITE_INTO_GROUP:
+		return self.IsContactBlocked(operation.GetParam2())
+	case prot.OpType_NOTIFIED_ADD_CONTACT:
+		fallthrough
+	case prot.OpType_NOTIFIED_RECOMMEND_CONTACT:
+		return self.IsContactBlocked(operation.GetParam1())
+	}
+	return false
+}
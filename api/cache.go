@@ -0,0 +1,307 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CACHE_PREVIEW   = "preview"
+	CACHE_IMAGE     = "image"
+	CACHE_STICKER   = "sticker"
+	CACHE_THUMBNAIL = "thumbnail"
+
+	CACHE_DEFAULT_LIMIT = 200 * 1024 * 1024
+	CACHE_TMP_SUFFIX    = ".tmp"
+	CACHE_TMP_EXPIRE    = time.Hour
+)
+
+var CacheCategories = []string{CACHE_PREVIEW, CACHE_IMAGE, CACHE_STICKER, CACHE_THUMBNAIL}
+
+type cacheFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+type cacheFileSlice []*cacheFile
+
+func (s cacheFileSlice) Less(i, j int) bool {
+	return s[i].ModTime.Before(s[j].ModTime)
+}
+
+func (s cacheFileSlice) Swap(i, j int) {
+	tmp := s[i]
+	s[i] = s[j]
+	s[j] = tmp
+}
+
+func (s cacheFileSlice) Len() int {
+	return len(s)
+}
+
+type MediaCache struct {
+	DirPath  string
+	Limit    int64
+	Download func(url, filePath string) error
+
+	used     int64
+	scanned  bool
+	inflight map[string]chan struct{}
+	lock     sync.Mutex
+}
+
+func NewMediaCache(dirPath string, limit int64, download func(url, filePath string) error) (*MediaCache, error) {
+	for _, category := range CacheCategories {
+		err := os.MkdirAll(path.Join(dirPath, category), os.FileMode(0700))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limit <= 0 {
+		limit = CACHE_DEFAULT_LIMIT
+	}
+	return &MediaCache{DirPath: dirPath, Limit: limit, Download: download,
+		inflight: make(map[string]chan struct{})}, nil
+}
+
+func (self *MediaCache) GetFilePath(category string, name string) string {
+	return path.Join(self.DirPath, category, path.Base(name))
+}
+
+func (self *MediaCache) lookup(filePath string) string {
+	if _, err := os.Stat(filePath); err == nil {
+		return filePath
+	}
+	if path.Ext(filePath) != "" {
+		return ""
+	}
+	matches, err := filepath.Glob(filePath + ".*")
+	if err != nil {
+		return ""
+	}
+	for _, match := range matches {
+		if !strings.HasSuffix(match, CACHE_TMP_SUFFIX) {
+			return match
+		}
+	}
+	return ""
+}
+
+func (self *MediaCache) acquire(key string) {
+	for {
+		self.lock.Lock()
+		wait, busy := self.inflight[key]
+		if !busy {
+			self.inflight[key] = make(chan struct{})
+			self.lock.Unlock()
+			return
+		}
+		self.lock.Unlock()
+		<-wait
+	}
+}
+
+func (self *MediaCache) release(key string) {
+	self.lock.Lock()
+	close(self.inflight[key])
+	delete(self.inflight, key)
+	self.lock.Unlock()
+}
+
+func (self *MediaCache) Get(category string, name string, url string) (string, error) {
+	return self.GetWith(category, name, url, self.Download)
+}
+
+func (self *MediaCache) GetWith(category string, name string, url string, download func(url, filePath string) error) (string, error) {
+	filePath := self.GetFilePath(category, name)
+	self.acquire(filePath)
+	defer self.release(filePath)
+	if cached := self.lookup(filePath); cached != "" {
+		if CheckCacheFile(cached) == nil {
+			Touch(cached)
+			return cached, nil
+		}
+		os.Remove(cached)
+	}
+
+	tmpFilePath := filePath + CACHE_TMP_SUFFIX
+	err := download(url, tmpFilePath)
+	if err == nil {
+		err = CheckCacheFile(tmpFilePath)
+	}
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return "", err
+	}
+	if path.Ext(filePath) == "" {
+		contentType, err := SniffFile(tmpFilePath)
+		if err == nil {
+			filePath += ExtensionByType(contentType)
+		}
+	}
+	err = os.Rename(tmpFilePath, filePath)
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return "", err
+	}
+	self.Added(filePath)
+	return filePath, nil
+}
+
+func (self *MediaCache) Added(filePath string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.scanned {
+		self.evict()
+		return
+	}
+	self.used += info.Size()
+	if self.used > self.Limit {
+		self.evict()
+	}
+}
+
+func (self *MediaCache) scan() (cacheFileSlice, error) {
+	files := make(cacheFileSlice, 0)
+	now := time.Now()
+	for _, category := range CacheCategories {
+		matches, err := filepath.Glob(path.Join(self.DirPath, category, "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if strings.HasSuffix(match, CACHE_TMP_SUFFIX) {
+				if now.Sub(info.ModTime()) > CACHE_TMP_EXPIRE {
+					os.Remove(match)
+				}
+				continue
+			}
+			files = append(files, &cacheFile{match, info.Size(), info.ModTime()})
+		}
+	}
+	return files, nil
+}
+
+func (self *MediaCache) evict() error {
+	files, err := self.scan()
+	if err != nil {
+		return err
+	}
+	sort.Sort(files)
+	var used int64
+	for _, file := range files {
+		used += file.Size
+	}
+	for _, file := range files {
+		if used <= self.Limit {
+			break
+		}
+		if os.Remove(file.Path) == nil {
+			used -= file.Size
+		}
+	}
+	self.used = used
+	self.scanned = true
+	return nil
+}
+
+func (self *MediaCache) Usage() (size int64, count int, err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	files, err := self.scan()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, file := range files {
+		size += file.Size
+	}
+	self.used = size
+	self.scanned = true
+	return size, len(files), nil
+}
+
+func (self *MediaCache) Clear() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	files, err := self.scan()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		os.Remove(file.Path)
+	}
+	self.used = 0
+	self.scanned = true
+	return nil
+}
+
+func Touch(filePath string) {
+	now := time.Now()
+	os.Chtimes(filePath, now, now)
+}
+
+func CheckCacheFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return errors.New("Empty cache file.")
+	}
+
+	_, format, err := image.DecodeConfig(file)
+	if err != nil {
+		head := make([]byte, SNIFF_LENGTH)
+		n, _ := file.ReadAt(head, 0)
+		if !strings.HasPrefix(SniffContentType(head[:n]), "image/") {
+			return errors.New("Cache file is not an image.")
+		}
+		return nil
+	}
+	tail := make([]byte, 16)
+	if info.Size() < int64(len(tail)) {
+		tail = tail[:info.Size()]
+	}
+	_, err = file.ReadAt(tail, info.Size()-int64(len(tail)))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch format {
+	case "jpeg":
+		if !bytes.Contains(tail, []byte{0xff, 0xd9}) {
+			return errors.New("Truncated JPEG file.")
+		}
+	case "png":
+		if !bytes.Contains(tail, []byte("IEND")) {
+			return errors.New("Truncated PNG file.")
+		}
+	case "gif":
+		if bytes.LastIndexByte(tail, 0x3b) < 0 {
+			return errors.New("Truncated GIF file.")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+func encodeTestImage(t *testing.T, format string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestFile(t *testing.T, filePath string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		data := encodeTestImage(t, format)
+		filePath := path.Join(dir, format)
+		writeTestFile(t, filePath, data, time.Time{})
+		if err := CheckCacheFile(filePath); err != nil {
+			t.Errorf("complete %s rejected: %v", format, err)
+		}
+		writeTestFile(t, filePath, data[:len(data)-12], time.Time{})
+		if err := CheckCacheFile(filePath); err == nil {
+			t.Errorf("truncated %s accepted", format)
+		}
+	}
+
+	filePath := path.Join(dir, "error.html")
+	writeTestFile(t, filePath, []byte("<html><body>Not Found</body></html>"), time.Time{})
+	if CheckCacheFile(filePath) == nil {
+		t.Error("HTML error page accepted as an image")
+	}
+	writeTestFile(t, filePath, nil, time.Time{})
+	if CheckCacheFile(filePath) == nil {
+		t.Error("empty file accepted")
+	}
+}
+
+func TestMediaCacheGet(t *testing.T) {
+	data := encodeTestImage(t, "png")
+	var downloads int
+	var lock sync.Mutex
+	cache, err := NewMediaCache(t.TempDir(), 0, func(url, filePath string) error {
+		lock.Lock()
+		downloads += 1
+		lock.Unlock()
+		return os.WriteFile(filePath, data, 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			filePath, err := cache.Get(CACHE_STICKER, "sticker", "http://example.com/sticker")
+			if err != nil {
+				t.Error(err)
+			} else if path.Ext(filePath) != ".png" {
+				t.Errorf("cached file %q has no .png extension", filePath)
+			}
+		}()
+	}
+	wg.Wait()
+	if downloads != 1 {
+		t.Errorf("downloaded %d times; want 1", downloads)
+	}
+}
+
+func TestMediaCacheGetRejectsBadDownloads(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewMediaCache(dir, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := func(url, filePath string) error {
+		os.WriteFile(filePath, []byte("partial"), 0600)
+		return errors.New("connection reset")
+	}
+	html := func(url, filePath string) error {
+		return os.WriteFile(filePath, []byte("<html>error</html>"), 0600)
+	}
+	for _, download := range []func(url, filePath string) error{failing, html} {
+		if _, err := cache.GetWith(CACHE_IMAGE, "image", "http://example.com/image", download); err == nil {
+			t.Error("bad download cached")
+		}
+		if cached := cache.lookup(cache.GetFilePath(CACHE_IMAGE, "image")); cached != "" {
+			t.Errorf("bad download left %q behind", cached)
+		}
+		if _, err := os.Stat(cache.GetFilePath(CACHE_IMAGE, "image") + CACHE_TMP_SUFFIX); err == nil {
+			t.Error("bad download left its temporary file behind")
+		}
+	}
+}
+
+func TestMediaCacheEvict(t *testing.T) {
+	data := encodeTestImage(t, "png")
+	dir := t.TempDir()
+	cache, err := NewMediaCache(dir, int64(len(data))*3, func(url, filePath string) error {
+		return os.WriteFile(filePath, data, 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for idx, name := range []string{"a.png", "b.png", "c.png"} {
+		writeTestFile(t, cache.GetFilePath(CACHE_IMAGE, name), data, now.Add(time.Duration(idx-10)*time.Minute))
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(cache.GetFilePath(CACHE_IMAGE, name))
+		return err == nil
+	}
+
+	if _, err := cache.Get(CACHE_IMAGE, "d.png", "http://example.com/d"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("a.png") || !exists("b.png") || !exists("c.png") || !exists("d.png") {
+		t.Error("adding d.png should evict only the oldest file a.png")
+	}
+
+	if _, err := cache.Get(CACHE_IMAGE, "b.png", "http://example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get(CACHE_IMAGE, "e.png", "http://example.com/e"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("b.png") || exists("c.png") || !exists("d.png") || !exists("e.png") {
+		t.Error("reading b.png should keep it and evict the least recently used c.png")
+	}
+	size, count, err := cache.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || size != int64(len(data))*3 {
+		t.Errorf("usage = %d bytes in %d files; want %d bytes in 3 files", size, count, len(data)*3)
+	}
+}
+
+func TestMediaCacheStaleTmpFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewMediaCache(dir, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := cache.GetFilePath(CACHE_PREVIEW, "stale"+CACHE_TMP_SUFFIX)
+	fresh := cache.GetFilePath(CACHE_PREVIEW, "fresh"+CACHE_TMP_SUFFIX)
+	writeTestFile(t, stale, []byte("stale"), time.Now().Add(-2*CACHE_TMP_EXPIRE))
+	writeTestFile(t, fresh, []byte("fresh"), time.Time{})
+
+	_, count, err := cache.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("temporary files counted as cached: %d", count)
+	}
+	if _, err := os.Stat(stale); err == nil {
+		t.Error("stale temporary file was not removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("in-progress temporary file was removed")
+	}
+}
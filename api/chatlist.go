@@ -0,0 +1,231 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+type ChatEntry struct {
+	Id          string
+	Name        string
+	MidType     prot.MIDType
+	UnreadCount int64
+	LastMessage *prot.Message
+	LastTime    time.Time
+}
+
+type ChatEntrySlice []*ChatEntry
+
+func (s ChatEntrySlice) Less(i, j int) bool {
+	return s[i].LastTime.After(s[j].LastTime)
+}
+
+func (s ChatEntrySlice) Swap(i, j int) {
+	tmp := s[i]
+	s[i] = s[j]
+	s[j] = tmp
+}
+
+func (s ChatEntrySlice) Len() int {
+	return len(s)
+}
+
+type ChatList struct {
+	chats map[string]*ChatEntry
+	lock  sync.Mutex
+}
+
+func NewChatList() *ChatList {
+	return &ChatList{chats: make(map[string]*ChatEntry)}
+}
+
+func MessagePreview(message *prot.Message) string {
+	if message == nil {
+		return ""
+	}
+	switch message.GetContentType() {
+	case prot.ContentType_NONE:
+		return message.GetText()
+	case prot.ContentType_IMAGE:
+		return "[Photo]"
+	case prot.ContentType_VIDEO:
+		return "[Video]"
+	case prot.ContentType_AUDIO:
+		return "[Voice message]"
+	case prot.ContentType_STICKER:
+		return "[Sticker]"
+	case prot.ContentType_FILE:
+		if name := message.GetContentMetadata()["FILE_NAME"]; name != "" {
+			return "[File] " + name
+		}
+		return "[File]"
+	case prot.ContentType_LOCATION:
+		if location := message.GetLocation(); location != nil && location.GetTitle() != "" {
+			return "[Location] " + location.GetTitle()
+		}
+		return "[Location]"
+	case prot.ContentType_CONTACT:
+		if _, name := GetSharedContact(message); name != "" {
+			return "[Contact] " + name
+		}
+		return "[Contact]"
+	}
+	return "[" + message.GetContentType().String() + "]"
+}
+
+func GetChatIdOfMessage(ownId string, message *prot.Message) string {
+	fromId := message.GetFrom()
+	toId := message.GetTo()
+	if fromId == ownId || toId != ownId {
+		return toId
+	}
+	return fromId
+}
+
+func (self *LineClient) GetMessageBoxWrapUps() ([]*prot.TMessageBoxWrapUp, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	start := int32(1)
+	count := int32(50)
+	wrapUps := make([]*prot.TMessageBoxWrapUp, 0)
+	for {
+		channel, err := self.client.GetMessageBoxCompactWrapUpList(start, count)
+		if err != nil {
+			return nil, err
+		}
+		wrapUps = append(wrapUps, channel.MessageBoxWrapUpList...)
+		if len(channel.MessageBoxWrapUpList) == int(count) {
+			start += count
+		} else {
+			break
+		}
+	}
+	return wrapUps, nil
+}
+
+func (self *ChatList) Refresh(client *LineClient) error {
+	wrapUps, err := client.GetMessageBoxWrapUps()
+	if err != nil {
+		return err
+	}
+	chats := make(map[string]*ChatEntry)
+	unnamed := make([]*ChatEntry, 0)
+	for _, wrapUp := range wrapUps {
+		messageBox := wrapUp.GetMessageBox()
+		if messageBox == nil {
+			continue
+		}
+		chat := &ChatEntry{
+			Id:          messageBox.GetId(),
+			Name:        wrapUp.GetName(),
+			MidType:     messageBox.GetMidType(),
+			UnreadCount: messageBox.GetUnreadCount(),
+			LastTime:    time.Unix(0, messageBox.GetLastModifiedTime()*int64(time.Millisecond)),
+		}
+		if lastMessages := messageBox.GetLastMessages(); len(lastMessages) > 0 {
+			chat.LastMessage = lastMessages[0]
+			chat.LastTime = MessageTime(chat.LastMessage)
+		}
+		if chat.Name == "" {
+			if entity := client.getLoadedEntityById(chat.Id); entity != nil {
+				chat.Name = entity.GetName()
+			} else {
+				unnamed = append(unnamed, chat)
+			}
+		}
+		chats[chat.Id] = chat
+	}
+	if len(unnamed) > 0 {
+		client.RefreshContacts()
+		client.RefreshGroups()
+		client.RefreshRooms()
+		for _, chat := range unnamed {
+			if entity := client.getLoadedEntityById(chat.Id); entity != nil {
+				chat.Name = entity.GetName()
+			}
+		}
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for id, chat := range self.chats {
+		refreshed := chats[id]
+		if refreshed == nil {
+			chats[id] = chat
+			continue
+		}
+		if chat.LastTime.After(refreshed.LastTime) {
+			refreshed.LastMessage = chat.LastMessage
+			refreshed.LastTime = chat.LastTime
+			if chat.UnreadCount > refreshed.UnreadCount {
+				refreshed.UnreadCount = chat.UnreadCount
+			}
+		}
+	}
+	self.chats = chats
+	return nil
+}
+
+func (self *ChatList) AddMessage(id string, name string, message *prot.Message, unread bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	chat := self.chats[id]
+	if chat == nil {
+		chat = &ChatEntry{Id: id, Name: name, MidType: message.GetToType()}
+		self.chats[id] = chat
+	}
+	if unread {
+		chat.UnreadCount += 1
+	}
+	createdTime := MessageTime(message)
+	if createdTime.Before(chat.LastTime) {
+		return
+	}
+	chat.LastMessage = message
+	chat.LastTime = createdTime
+}
+
+func (self *ChatList) MarkRead(id string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	chat := self.chats[id]
+	if chat == nil || chat.UnreadCount == 0 {
+		return false
+	}
+	chat.UnreadCount = 0
+	return true
+}
+
+func (self *ChatList) Remove(id string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.chats[id] == nil {
+		return false
+	}
+	delete(self.chats, id)
+	return true
+}
+
+func (self *ChatList) GetUnreadCount() int64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var count int64 = 0
+	for _, chat := range self.chats {
+		count += chat.UnreadCount
+	}
+	return count
+}
+
+func (self *ChatList) Sorted() []*ChatEntry {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	chats := make(ChatEntrySlice, 0, len(self.chats))
+	for _, chat := range self.chats {
+		copied := *chat
+		chats = append(chats, &copied)
+	}
+	sort.Sort(chats)
+	return chats
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"sync"
@@ -19,10 +20,26 @@ const (
 	LINE_SESSION_NAVER_URL  = LINE_DOMAIN + "/authct/v1/keys/naver"
 	LINE_OBJECT_STORAGE_URL = "http://os.line.naver.jp/os/m/"
 	LINE_STICKER_URL        = "http://dl.stickershop.line.naver.jp/products/0/0/"
+	LINE_PROFILE_URL        = "http://dl.profile.line.naver.jp"
+	LINE_PROFILE_UPLOAD_URL = "http://os.line.naver.jp/talk/p/upload.nhn"
 	LINE_USER_AGENT         = "DESKTOP:MAC:10.9.4-MAVERICKS-x64(3.7.0)"
 	LINE_X_LINE_APPLICATION = "DESKTOPMAC\t3.7.0\tMAC\t10.9.4-MAVERICKS-x64"
 )
 
+var (
+	HttpClient = &http.Client{CheckRedirect: checkRedirect}
+)
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("Stopped after 10 redirects.")
+	}
+	if !IsLineMediaUrl(req.URL.String()) {
+		req.Header.Del("X-Line-Access")
+	}
+	return nil
+}
+
 type ContactSlice []*prot.Contact
 
 func (s ContactSlice) Less(i, j int) bool {
@@ -60,6 +77,10 @@ type LineClient struct {
 	Provider  prot.IdentityProvider
 	Contacts  ContactSlice
 	Groups    GroupSlice
+	Invited   GroupSlice
+	Blocked   ContactSlice
+	Hidden    ContactSlice
+	Settings  *prot.Settings
 	Rooms     []*prot.Room
 	AuthToken string
 	IP        string
@@ -68,6 +89,11 @@ type LineClient struct {
 	header    *http.Header
 	revision  int64
 	lock      sync.Mutex
+
+	LoginErrors      []error
+	serverTimeOffset int64
+	settingsSeq      int32
+	ownSettingsSeqs  map[int32]bool
 }
 
 func NewLineClient() (*LineClient, error) {
@@ -135,18 +161,17 @@ func (self *LineClient) RefreshGroups() ([]*prot.Group, error) {
 		return nil, err
 	}
 
-	joinedGroups, err := self.client.GetGroups(joinedIds)
+	self.Groups, err = self.client.GetGroups(joinedIds)
 	if err != nil {
 		return nil, err
 	}
+	sort.Sort(self.Groups)
 
-	self.Groups, err = self.client.GetGroups(invitedIds)
+	self.Invited, err = self.client.GetGroups(invitedIds)
 	if err != nil {
 		return nil, err
 	}
-
-	self.Groups = append(self.Groups, joinedGroups...)
-	sort.Sort(self.Groups)
+	sort.Sort(self.Invited)
 
 	return self.Groups, err
 
@@ -201,6 +226,15 @@ func (self *LineClient) GetGroupById(id string) *prot.Group {
 	return nil
 }
 
+func (self *LineClient) GetInvitedGroupById(id string) *prot.Group {
+	for _, group := range self.Invited {
+		if group.GetId() == id {
+			return group
+		}
+	}
+	return nil
+}
+
 func (self *LineClient) GetRoomById(id string) *prot.Room {
 	for _, room := range self.Rooms {
 		if room.GetMid() == id {
@@ -210,25 +244,32 @@ func (self *LineClient) GetRoomById(id string) *prot.Room {
 	return nil
 }
 
-func (self *LineClient) GetLineEntityById(id string) (LineEntity, error) {
+func (self *LineClient) getLoadedEntityById(id string) LineEntity {
 	contact := self.GetContactById(id)
 	if contact != nil {
-		return NewLineContactWrapper(contact), nil
+		return NewLineContactWrapper(contact)
 	}
 	group := self.GetGroupById(id)
 	if group != nil {
-		return NewLineGroupWrapper(group), nil
+		return NewLineGroupWrapper(group)
 	}
 	room := self.GetRoomById(id)
 	if room != nil {
-		return NewLineRoomWrapper(room), nil
+		return NewLineRoomWrapper(room)
+	}
+	return nil
+}
+
+func (self *LineClient) GetLineEntityById(id string) (LineEntity, error) {
+	if entity := self.getLoadedEntityById(id); entity != nil {
+		return entity, nil
 	}
 
 	_, err := self.RefreshContacts()
 	if err != nil {
 		return nil, err
 	}
-	contact = self.GetContactById(id)
+	contact := self.GetContactById(id)
 	if contact != nil {
 		return NewLineContactWrapper(contact), nil
 	}
@@ -237,7 +278,7 @@ func (self *LineClient) GetLineEntityById(id string) (LineEntity, error) {
 	if err != nil {
 		return nil, err
 	}
-	group = self.GetGroupById(id)
+	group := self.GetGroupById(id)
 	if group != nil {
 		return NewLineGroupWrapper(group), nil
 	}
@@ -246,7 +287,7 @@ func (self *LineClient) GetLineEntityById(id string) (LineEntity, error) {
 	if err != nil {
 		return nil, err
 	}
-	room = self.GetRoomById(id)
+	room := self.GetRoomById(id)
 	if room != nil {
 		return NewLineRoomWrapper(room), nil
 	}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const SERVER_TIME_SYNC_INTERVAL = 30 * time.Minute
+
+func (self *LineClient) SyncServerTime() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	before := time.Now()
+	serverTime, err := self.client.GetServerTime()
+	if err != nil {
+		return err
+	}
+	localTime := before.Add(time.Since(before) / 2)
+	atomic.StoreInt64(&self.serverTimeOffset, serverTime-toMilliseconds(localTime))
+	return nil
+}
+
+func (self *LineClient) ServerNow() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64(&self.serverTimeOffset)) * time.Millisecond)
+}
+
+func (self *LineClient) ServerTimestamp() int64 {
+	return toMilliseconds(self.ServerNow())
+}
+
+func (self *LineClient) GetMessageTime(message *prot.Message) time.Time {
+	if message.GetCreatedTime() <= 0 {
+		return self.ServerNow().Local()
+	}
+	return fromMilliseconds(message.GetCreatedTime()).Local()
+}
+
+func (self *LineClient) FormatMessageDate(messageTime time.Time) string {
+	today := self.ServerNow().Local()
+	if IsSameDay(messageTime, today) {
+		return "Today"
+	}
+	if IsSameDay(messageTime, today.AddDate(0, 0, -1)) {
+		return "Yesterday"
+	}
+	if messageTime.Year() == today.Year() {
+		return messageTime.Format("Mon, Jan 2")
+	}
+	return messageTime.Format("Mon, Jan 2, 2006")
+}
+
+func FormatMessageTime(messageTime time.Time) string {
+	return messageTime.Format("15:04")
+}
+
+func IsSameDay(a time.Time, b time.Time) bool {
+	aYear, aMonth, aDay := a.Date()
+	bYear, bMonth, bDay := b.Date()
+	return aYear == bYear && aMonth == bMonth && aDay == bDay
+}
+
+func toMilliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMilliseconds(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"sort"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func (self *LineClient) putContact(contact *prot.Contact) *prot.Contact {
+	for _, existing := range self.Contacts {
+		if existing.GetMid() == contact.GetMid() {
+			*existing = *contact
+			return existing
+		}
+	}
+	self.Contacts = append(self.Contacts, contact)
+	sort.Sort(self.Contacts)
+	return contact
+}
+
+func (self *LineClient) putContacts(contacts map[string]*prot.Contact) []*prot.Contact {
+	added := make([]*prot.Contact, 0, len(contacts))
+	for _, contact := range contacts {
+		added = append(added, self.putContact(contact))
+	}
+	return added
+}
+
+func firstContact(contacts map[string]*prot.Contact) (*prot.Contact, error) {
+	for _, contact := range contacts {
+		return contact, nil
+	}
+	return nil, errors.New("Contact not found.")
+}
+
+func (self *LineClient) RefreshContact(mid string) (*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.GetContacts([]string{mid})
+	if err != nil {
+		return nil, err
+	}
+	if len(contacts) == 0 {
+		return nil, errors.New("Contact not found.")
+	}
+	return self.putContact(contacts[0]), nil
+}
+
+func GetSharedContact(message *prot.Message) (mid string, displayName string) {
+	meta := message.GetContentMetadata()
+	return meta["mid"], meta["displayName"]
+}
+
+func (self *LineClient) FetchContact(mid string) (*prot.Contact, error) {
+	contact := self.GetContactById(mid)
+	if contact != nil {
+		return contact, nil
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.GetContacts([]string{mid})
+	if err != nil {
+		return nil, err
+	}
+	if len(contacts) == 0 {
+		return nil, errors.New("Contact not found.")
+	}
+	return contacts[0], nil
+}
+
+func (self *LineClient) FindContactByUserid(userid string) (*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.FindContactByUserid(userid)
+}
+
+func (self *LineClient) FindContactByUserTicket(ticketId string) (*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.FindContactByUserTicket(ticketId)
+}
+
+func (self *LineClient) FindContactByEmail(email string) (*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindContactsByEmail(map[string]bool{email: true})
+	if err != nil {
+		return nil, err
+	}
+	return firstContact(contacts)
+}
+
+func (self *LineClient) FindContactByPhone(phone string) (*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindContactsByPhone(map[string]bool{phone: true})
+	if err != nil {
+		return nil, err
+	}
+	return firstContact(contacts)
+}
+
+func (self *LineClient) FindAndAddContactsByUserid(userid string) ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindAndAddContactsByUserid(0, userid)
+	if err != nil {
+		return nil, err
+	}
+	return self.putContacts(contacts), nil
+}
+
+func (self *LineClient) FindAndAddContactsByEmail(email string) ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindAndAddContactsByEmail(0, map[string]bool{email: true})
+	if err != nil {
+		return nil, err
+	}
+	return self.putContacts(contacts), nil
+}
+
+func (self *LineClient) FindAndAddContactsByPhone(phone string) ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindAndAddContactsByPhone(0, map[string]bool{phone: true})
+	if err != nil {
+		return nil, err
+	}
+	return self.putContacts(contacts), nil
+}
+
+func (self *LineClient) FindAndAddContactsByMid(mid string) ([]*prot.Contact, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	contacts, err := self.client.FindAndAddContactsByMid(0, mid)
+	if err != nil {
+		return nil, err
+	}
+	return self.putContacts(contacts), nil
+}
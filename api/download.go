@@ -0,0 +1,428 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DownloadState int
+
+const (
+	DOWNLOAD_QUEUED   DownloadState = 0
+	DOWNLOAD_ACTIVE   DownloadState = 1
+	DOWNLOAD_DONE     DownloadState = 2
+	DOWNLOAD_FAILED   DownloadState = 3
+	DOWNLOAD_CANCELED DownloadState = 4
+
+	DOWNLOAD_DEFAULT_LIMIT = 3
+	DOWNLOAD_PART_SUFFIX   = ".part"
+	DOWNLOAD_UPDATE_DELAY  = 200 * time.Millisecond
+	DOWNLOAD_FETCH_TIMEOUT = time.Minute
+)
+
+func (s DownloadState) String() string {
+	switch s {
+	case DOWNLOAD_QUEUED:
+		return "Queued"
+	case DOWNLOAD_ACTIVE:
+		return "Downloading"
+	case DOWNLOAD_DONE:
+		return "Finished"
+	case DOWNLOAD_FAILED:
+		return "Failed"
+	case DOWNLOAD_CANCELED:
+		return "Canceled"
+	}
+	return "Unknown"
+}
+
+type Download struct {
+	Url string
+
+	filePath string
+	manager  *DownloadManager
+	state    DownloadState
+	received int64
+	total    int64
+	err      error
+	cancel   context.CancelFunc
+	lock     sync.Mutex
+}
+
+func (self *Download) GetFilePath() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.filePath
+}
+
+func (self *Download) GetState() DownloadState {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.state
+}
+
+func (self *Download) GetProgress() (received int64, total int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.received, self.total
+}
+
+func (self *Download) GetError() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.err
+}
+
+func (self *Download) IsFinished() bool {
+	state := self.GetState()
+	return state == DOWNLOAD_DONE || state == DOWNLOAD_FAILED || state == DOWNLOAD_CANCELED
+}
+
+func (self *Download) setState(state DownloadState, err error) {
+	self.lock.Lock()
+	self.state = state
+	self.err = err
+	self.lock.Unlock()
+	self.manager.notify(self)
+}
+
+func (self *Download) Cancel() {
+	self.lock.Lock()
+	state := self.state
+	cancel := self.cancel
+	if state == DOWNLOAD_QUEUED {
+		self.state = DOWNLOAD_CANCELED
+	}
+	self.lock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if state == DOWNLOAD_QUEUED {
+		self.manager.notify(self)
+	}
+}
+
+func (self *Download) Resume() {
+	state := self.GetState()
+	if state != DOWNLOAD_FAILED && state != DOWNLOAD_CANCELED {
+		return
+	}
+	self.manager.start(self)
+}
+
+type DownloadManager struct {
+	Client   *http.Client
+	Header   func() *http.Header
+	OnUpdate func(*Download)
+
+	downloads []*Download
+	slots     chan struct{}
+	lock      sync.Mutex
+}
+
+func NewDownloadManager(limit int, header func() *http.Header) *DownloadManager {
+	if limit <= 0 {
+		limit = DOWNLOAD_DEFAULT_LIMIT
+	}
+	return &DownloadManager{
+		Client: HttpClient,
+		Header: header,
+		slots:  make(chan struct{}, limit)}
+}
+
+func (self *DownloadManager) notify(download *Download) {
+	if self.OnUpdate != nil {
+		go self.OnUpdate(download)
+	}
+}
+
+func getUrlHost(rawurl string) string {
+	parsed, err := neturl.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+func IsLineMediaUrl(rawurl string) bool {
+	parsed, err := neturl.Parse(rawurl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	host := parsed.Hostname()
+	return host != "" &&
+		(host == getUrlHost(LINE_OBJECT_STORAGE_URL) || host == getUrlHost(LINE_STICKER_URL))
+}
+
+func (self *DownloadManager) newRequest(ctx context.Context, url string, auth bool) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth && self.Header != nil && IsLineMediaUrl(url) {
+		for key, values := range *self.Header() {
+			req.Header[key] = values
+		}
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (self *DownloadManager) Fetch(url string, filePath string) error {
+	return self.fetch(url, filePath, true)
+}
+
+func (self *DownloadManager) FetchPublic(url string, filePath string) error {
+	return self.fetch(url, filePath, false)
+}
+
+func (self *DownloadManager) fetch(url string, filePath string, auth bool) error {
+	self.slots <- struct{}{}
+	defer func() { <-self.slots }()
+	ctx, cancel := context.WithTimeout(context.Background(), DOWNLOAD_FETCH_TIMEOUT)
+	defer cancel()
+	req, err := self.newRequest(ctx, url, auth)
+	if err != nil {
+		return err
+	}
+	res, err := self.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.New("Download failed: " + res.Status)
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(file, res.Body)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	if res.ContentLength >= 0 && n != res.ContentLength {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (self *DownloadManager) Add(url string, filePath string) *Download {
+	download := &Download{Url: url, filePath: filePath, manager: self}
+	self.lock.Lock()
+	self.downloads = append(self.downloads, download)
+	self.lock.Unlock()
+	self.start(download)
+	return download
+}
+
+func (self *DownloadManager) Downloads() []*Download {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	downloads := make([]*Download, len(self.downloads))
+	copy(downloads, self.downloads)
+	return downloads
+}
+
+func (self *DownloadManager) ClearFinished() {
+	self.lock.Lock()
+	downloads := make([]*Download, 0, len(self.downloads))
+	for _, download := range self.downloads {
+		if !download.IsFinished() {
+			downloads = append(downloads, download)
+		}
+	}
+	self.downloads = downloads
+	self.lock.Unlock()
+	self.notify(nil)
+}
+
+func (self *DownloadManager) start(download *Download) {
+	ctx, cancel := context.WithCancel(context.Background())
+	download.lock.Lock()
+	download.state = DOWNLOAD_QUEUED
+	download.err = nil
+	download.cancel = cancel
+	download.lock.Unlock()
+	self.notify(download)
+
+	go func() {
+		defer cancel()
+		select {
+		case self.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-self.slots }()
+		if download.GetState() != DOWNLOAD_QUEUED {
+			return
+		}
+		download.setState(DOWNLOAD_ACTIVE, nil)
+		err := self.run(ctx, download)
+		switch {
+		case ctx.Err() != nil:
+			download.setState(DOWNLOAD_CANCELED, nil)
+		case err != nil:
+			download.setState(DOWNLOAD_FAILED, err)
+		default:
+			download.setState(DOWNLOAD_DONE, nil)
+		}
+	}()
+}
+
+func (self *DownloadManager) get(ctx context.Context, url string, offset int64) (*http.Response, error) {
+	req, err := self.newRequest(ctx, url, true)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	return self.Client.Do(req)
+}
+
+func parseContentRange(value string) (start int64, size int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, false
+	}
+	value = strings.TrimPrefix(value, "bytes ")
+	slash := strings.LastIndex(value, "/")
+	if slash < 0 {
+		return 0, 0, false
+	}
+	var err error
+	start, size = -1, -1
+	if value[slash+1:] != "*" {
+		size, err = strconv.ParseInt(value[slash+1:], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+	if value[:slash] != "*" {
+		dash := strings.Index(value, "-")
+		if dash < 0 || dash > slash {
+			return 0, 0, false
+		}
+		start, err = strconv.ParseInt(value[:dash], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
+
+func (self *DownloadManager) run(ctx context.Context, download *Download) error {
+	filePath := download.GetFilePath()
+	partFilePath := filePath + DOWNLOAD_PART_SUFFIX
+	var offset int64
+	if info, err := os.Stat(partFilePath); err == nil {
+		offset = info.Size()
+	}
+
+	res, err := self.get(ctx, download.Url, offset)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		start, size, _ := parseContentRange(res.Header.Get("Content-Range"))
+		switch {
+		case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && size == offset:
+			res.Body.Close()
+			download.lock.Lock()
+			download.received = offset
+			download.total = offset
+			download.lock.Unlock()
+			return os.Rename(partFilePath, filePath)
+		case res.StatusCode == http.StatusRequestedRangeNotSatisfiable,
+			res.StatusCode == http.StatusPartialContent && start != offset:
+			res.Body.Close()
+			offset = 0
+			res, err = self.get(ctx, download.Url, offset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	defer res.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		start, _, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return errors.New("Download failed: unexpected Content-Range.")
+		}
+		if offset > 0 {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+	case http.StatusOK:
+		offset = 0
+	default:
+		return errors.New("Download failed: " + res.Status)
+	}
+	total := int64(-1)
+	if res.ContentLength >= 0 {
+		total = offset + res.ContentLength
+	}
+	download.lock.Lock()
+	download.received = offset
+	download.total = total
+	download.lock.Unlock()
+
+	file, err := os.OpenFile(partFilePath, flags, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	err = self.copy(download, file, res.Body)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	received, _ := download.GetProgress()
+	if total >= 0 && received != total {
+		return io.ErrUnexpectedEOF
+	}
+	return os.Rename(partFilePath, filePath)
+}
+
+func (self *DownloadManager) copy(download *Download, dst io.Writer, src io.Reader) error {
+	buf := make([]byte, 32*1024)
+	lastUpdate := time.Now()
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			_, writeErr := dst.Write(buf[:n])
+			if writeErr != nil {
+				return writeErr
+			}
+			download.lock.Lock()
+			download.received += int64(n)
+			download.lock.Unlock()
+			if time.Since(lastUpdate) >= DOWNLOAD_UPDATE_DELAY {
+				lastUpdate = time.Now()
+				self.notify(download)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
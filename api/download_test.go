@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testDownloadContent = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+func serveTestContent(w http.ResponseWriter, r *http.Request) {
+	http.ServeContent(w, r, "content", time.Time{}, bytes.NewReader(testDownloadContent))
+}
+
+func waitDownload(t *testing.T, download *Download) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !download.IsFinished() {
+		if time.Now().After(deadline) {
+			t.Fatal("download did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func checkDownloadedFile(t *testing.T, filePath string) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, testDownloadContent) {
+		t.Errorf("downloaded %d bytes that differ from the %d served", len(data), len(testDownloadContent))
+	}
+	if _, err := os.Stat(filePath + DOWNLOAD_PART_SUFFIX); err == nil {
+		t.Error("partial file left behind")
+	}
+}
+
+func resumeTestDownload(t *testing.T, handler http.HandlerFunc, part []byte) (*Download, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	filePath := path.Join(t.TempDir(), "file")
+	if part != nil {
+		if err := os.WriteFile(filePath+DOWNLOAD_PART_SUFFIX, part, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	download := NewDownloadManager(1, nil).Add(server.URL, filePath)
+	waitDownload(t, download)
+	if download.GetState() != DOWNLOAD_DONE {
+		t.Fatalf("download state = %v (%v); want done", download.GetState(), download.GetError())
+	}
+	return download, filePath
+}
+
+func TestDownloadResume(t *testing.T) {
+	offset := len(testDownloadContent) / 3
+	var ranges []string
+	download, filePath := resumeTestDownload(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		serveTestContent(w, r)
+	}, testDownloadContent[:offset])
+	checkDownloadedFile(t, filePath)
+	if len(ranges) != 1 || ranges[0] != "bytes="+strconv.Itoa(offset)+"-" {
+		t.Errorf("requested ranges %q; want a single resume from %d", ranges, offset)
+	}
+	received, total := download.GetProgress()
+	if received != int64(len(testDownloadContent)) || total != received {
+		t.Errorf("progress = %d/%d; want %d", received, total, len(testDownloadContent))
+	}
+}
+
+func TestDownloadResumeCompletePart(t *testing.T) {
+	_, filePath := resumeTestDownload(t, serveTestContent, testDownloadContent)
+	checkDownloadedFile(t, filePath)
+}
+
+func TestDownloadResumeIgnoredRange(t *testing.T) {
+	_, filePath := resumeTestDownload(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testDownloadContent)
+	}, []byte("stale data"))
+	checkDownloadedFile(t, filePath)
+}
+
+func TestDownloadResumeMismatchedRange(t *testing.T) {
+	var requests int
+	_, filePath := resumeTestDownload(t, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if r.Header.Get("Range") == "" {
+			w.Write(testDownloadContent)
+			return
+		}
+		w.Header().Set("Content-Range", "bytes 0-9/"+strconv.Itoa(len(testDownloadContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testDownloadContent[:10])
+	}, testDownloadContent[:100])
+	checkDownloadedFile(t, filePath)
+	if requests != 2 {
+		t.Errorf("made %d requests; want a restart after the mismatched range", requests)
+	}
+}
+
+func TestDownloadCancel(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	manager := NewDownloadManager(1, nil)
+	download := manager.Add(server.URL, path.Join(t.TempDir(), "file"))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not start")
+	}
+	queued := manager.Add(server.URL, path.Join(t.TempDir(), "queued"))
+	queued.Cancel()
+	if queued.GetState() != DOWNLOAD_CANCELED {
+		t.Errorf("queued download state = %v; want canceled", queued.GetState())
+	}
+	download.Cancel()
+	waitDownload(t, download)
+	if download.GetState() != DOWNLOAD_CANCELED {
+		t.Errorf("active download state = %v; want canceled", download.GetState())
+	}
+}
+
+func TestFetchUsesSlots(t *testing.T) {
+	var active, peak int
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		active += 1
+		if active > peak {
+			peak = active
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		active -= 1
+		lock.Unlock()
+		serveTestContent(w, r)
+	}))
+	defer server.Close()
+	manager := NewDownloadManager(2, nil)
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for idx := 0; idx < 6; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			filePath := path.Join(dir, strconv.Itoa(idx))
+			if err := manager.FetchPublic(server.URL, filePath); err != nil {
+				t.Error(err)
+				return
+			}
+			checkDownloadedFile(t, filePath)
+		}(idx)
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("%d fetches ran at once; want at most 2", peak)
+	}
+}
+
+func TestNewRequestAuth(t *testing.T) {
+	manager := NewDownloadManager(1, func() *http.Header {
+		return &http.Header{"X-Line-Access": []string{"token"}}
+	})
+	cases := []struct {
+		url  string
+		auth bool
+		sent bool
+	}{
+		{LINE_OBJECT_STORAGE_URL + "123", true, true},
+		{LINE_STICKER_URL + "1/sticker.png", true, true},
+		{LINE_OBJECT_STORAGE_URL + "123", false, false},
+		{"http://example.com/image.png", true, false},
+		{"ftp://os.line.naver.jp/os/m/123", true, false},
+	}
+	for _, c := range cases {
+		req, err := manager.newRequest(context.Background(), c.url, c.auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sent := req.Header.Get("X-Line-Access") != ""; sent != c.sent {
+			t.Errorf("newRequest(%q, %v) sent auth = %v; want %v", c.url, c.auth, sent, c.sent)
+		}
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	newRequest := func(url string) *http.Request {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Line-Access", "token")
+		return req
+	}
+	req := newRequest(LINE_OBJECT_STORAGE_URL + "456")
+	if err := checkRedirect(req, []*http.Request{req}); err != nil || req.Header.Get("X-Line-Access") == "" {
+		t.Errorf("redirect within LINE hosts dropped auth: %v", err)
+	}
+	req = newRequest("http://example.com/cdn/456")
+	if err := checkRedirect(req, []*http.Request{req}); err != nil || req.Header.Get("X-Line-Access") != "" {
+		t.Errorf("redirect off LINE hosts kept auth: %v", err)
+	}
+	if err := checkRedirect(req, make([]*http.Request, 10)); err == nil {
+		t.Error("redirect loop not stopped")
+	}
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+type ExportFormat int
+
+const (
+	EXPORT_JSON ExportFormat = 0
+	EXPORT_HTML ExportFormat = 1
+	EXPORT_TEXT ExportFormat = 2
+)
+
+func (self ExportFormat) String() string {
+	switch self {
+	case EXPORT_JSON:
+		return "JSON lines"
+	case EXPORT_HTML:
+		return "HTML"
+	case EXPORT_TEXT:
+		return "Plain text"
+	}
+	return "<UNSET>"
+}
+
+func (self ExportFormat) Extension() string {
+	switch self {
+	case EXPORT_JSON:
+		return ".jsonl"
+	case EXPORT_HTML:
+		return ".html"
+	}
+	return ".txt"
+}
+
+type ExportedMessage struct {
+	Id              string            `json:"id"`
+	Time            time.Time         `json:"time"`
+	From            string            `json:"from"`
+	Sender          string            `json:"sender"`
+	To              string            `json:"to"`
+	ContentType     string            `json:"contentType"`
+	Text            string            `json:"text,omitempty"`
+	Location        *prot.Location    `json:"location,omitempty"`
+	ContentMetadata map[string]string `json:"contentMetadata,omitempty"`
+	Media           string            `json:"-"`
+}
+
+type ChatExporter struct {
+	Client   *LineClient
+	Entity   LineEntity
+	Format   ExportFormat
+	Since    time.Time
+	Until    time.Time
+	History  *HistoryStore
+	Download func(url, filePath string) error
+	names    map[string]string
+}
+
+func NewChatExporter(client *LineClient, entity LineEntity, format ExportFormat) *ChatExporter {
+	return &ChatExporter{Client: client, Entity: entity, Format: format,
+		names: make(map[string]string)}
+}
+
+func (self *ChatExporter) Export(filePath string) (int, error) {
+	messageBox, err := self.Client.GetMessageBox(self.Entity.GetId())
+	if err != nil {
+		return 0, err
+	}
+	messages, err := self.Client.GetMessagesBetween(messageBox, self.Since, self.Until)
+	if err != nil {
+		return 0, err
+	}
+	messages, err = self.mergeHistory(messages)
+	if err != nil {
+		return 0, err
+	}
+
+	tmpFilePath := filePath + ".tmp"
+	file, err := os.Create(tmpFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpFilePath)
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	exported := make([]*ExportedMessage, 0, len(messages))
+	for _, message := range messages {
+		exported = append(exported, self.exportMessage(message))
+	}
+
+	switch self.Format {
+	case EXPORT_JSON:
+		err = self.writeJson(writer, exported)
+	case EXPORT_HTML:
+		self.downloadMedia(filePath, exported)
+		err = self.writeHtml(writer, exported)
+	default:
+		err = self.writeText(writer, exported)
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = writer.Flush()
+	if err != nil {
+		return 0, err
+	}
+	err = file.Close()
+	if err != nil {
+		return 0, err
+	}
+	return len(exported), os.Rename(tmpFilePath, filePath)
+}
+
+func (self *ChatExporter) mergeHistory(messages []*prot.Message) ([]*prot.Message, error) {
+	if self.History == nil {
+		return messages, nil
+	}
+	history, err := self.History.Load(self.Entity.GetId())
+	if err != nil {
+		return nil, err
+	}
+	merged := MessageSlice(messages)
+	for _, message := range history {
+		createdTime := MessageTime(message)
+		if createdTime.Before(self.Since) ||
+			(!self.Until.IsZero() && createdTime.After(self.Until)) {
+			continue
+		}
+		merged = append(merged, message)
+	}
+	sort.Sort(merged)
+	return merged, nil
+}
+
+func (self *ChatExporter) getSenderName(id string) string {
+	if name, ok := self.names[id]; ok {
+		return name
+	}
+	name := "Unknown"
+	if strings.HasPrefix(id, IMPORTED_MID_PREFIX) {
+		return ""
+	}
+	if self.Client.Profile != nil && self.Client.Profile.GetMid() == id {
+		name = self.Client.Profile.GetDisplayName()
+	} else {
+		entity, err := self.Client.GetLineEntityById(id)
+		if err == nil && entity != nil {
+			name = entity.GetName()
+		}
+	}
+	self.names[id] = name
+	return name
+}
+
+func (self *ChatExporter) exportMessage(message *prot.Message) *ExportedMessage {
+	sender := self.getSenderName(message.GetFrom())
+	if sender == "" {
+		sender = message.ContentMetadata[IMPORTED_SENDER_META]
+	}
+	return &ExportedMessage{
+		Id:              message.GetId(),
+		Time:            MessageTime(message),
+		From:            message.GetFrom(),
+		Sender:          sender,
+		To:              message.GetTo(),
+		ContentType:     message.GetContentType().String(),
+		Text:            message.GetText(),
+		Location:        message.GetLocation(),
+		ContentMetadata: message.GetContentMetadata(),
+	}
+}
+
+func GetMessageContentUrl(contentType string, id string, meta map[string]string) string {
+	switch contentType {
+	case prot.ContentType_STICKER.String():
+		return LINE_STICKER_URL + meta["STKVER"] + "/" + meta["STKPKGID"] +
+			"/PC/stickers/" + meta["STKID"] + ".png"
+	case prot.ContentType_IMAGE.String():
+		if meta["PUBLIC"] == "TRUE" {
+			return meta["DOWNLOAD_URL"]
+		}
+		return LINE_OBJECT_STORAGE_URL + id
+	case prot.ContentType_VIDEO.String(), prot.ContentType_AUDIO.String():
+		return LINE_OBJECT_STORAGE_URL + id
+	}
+	return ""
+}
+
+func (self *ChatExporter) downloadMedia(filePath string, messages []*ExportedMessage) {
+	if self.Download == nil {
+		return
+	}
+	mediaDirName := path.Base(filePath) + "_files"
+	mediaDirPath := path.Join(path.Dir(filePath), mediaDirName)
+	for _, message := range messages {
+		if _, ok := message.ContentMetadata[IMPORTED_PLACEHOLDER_KEY]; ok {
+			continue
+		}
+		url := GetMessageContentUrl(message.ContentType, message.Id, message.ContentMetadata)
+		if url == "" {
+			continue
+		}
+		err := os.MkdirAll(mediaDirPath, os.FileMode(0700))
+		if err != nil {
+			return
+		}
+		name := message.Id
+		if message.ContentType == prot.ContentType_STICKER.String() {
+			name = message.ContentMetadata["STKID"] + ".png"
+		}
+		mediaPath := path.Join(mediaDirPath, name)
+		err = self.Download(url, mediaPath)
+		if err != nil {
+			os.Remove(mediaPath)
+			continue
+		}
+		mediaPath, err = AddFileExtension(mediaPath, "")
+		if err != nil {
+			continue
+		}
+		message.Media = path.Join(mediaDirName, path.Base(mediaPath))
+	}
+}
+
+func (self *ChatExporter) writeJson(writer io.Writer, messages []*ExportedMessage) error {
+	jsonEncoder := json.NewEncoder(writer)
+	for _, message := range messages {
+		err := jsonEncoder.Encode(message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *ChatExporter) writeText(writer io.Writer, messages []*ExportedMessage) error {
+	_, err := fmt.Fprintf(writer, "%s\n%s - %s\n\n", self.Entity.GetName(),
+		formatExportDate(self.Since, messages, 0), formatExportDate(self.Until, messages, -1))
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		text := message.Text
+		if message.ContentType != prot.ContentType_NONE.String() {
+			text = "[" + message.ContentType + "]"
+			if message.Text != "" {
+				text += " " + message.Text
+			}
+		}
+		if message.Location != nil {
+			text += " " + message.Location.GetTitle() + " " + message.Location.GetAddress() + " (" +
+				strconv.FormatFloat(message.Location.GetLatitude(), 'f', -1, 64) + ", " +
+				strconv.FormatFloat(message.Location.GetLongitude(), 'f', -1, 64) + ")"
+		}
+		_, err = fmt.Fprintf(writer, "%s\t%s\t%s\n",
+			message.Time.Local().Format("2006/01/02 15:04:05"), message.Sender, text)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(message.ContentMetadata))
+		for key := range message.ContentMetadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			_, err = fmt.Fprintf(writer, "\t\t%s=%s\n", key, message.ContentMetadata[key])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatExportDate(date time.Time, messages []*ExportedMessage, idx int) string {
+	if date.IsZero() {
+		if len(messages) == 0 {
+			return ""
+		}
+		if idx < 0 {
+			idx += len(messages)
+		}
+		date = messages[idx].Time
+	}
+	return date.Local().Format("2006/01/02")
+}
+
+var exportHtmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.Local().Format("2006/01/02 15:04:05")
+	},
+	"isImage": func(message *ExportedMessage) bool {
+		return message.ContentType == prot.ContentType_IMAGE.String() ||
+			message.ContentType == prot.ContentType_STICKER.String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; background: #ebffe6; }
+.message { margin: 6px 0; }
+.time { color: #888; font-size: small; }
+.sender { font-weight: bold; }
+.metadata { color: #666; font-size: small; }
+img { max-width: 320px; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Range}}</p>
+{{range .Messages}}<div class="message" id="{{.Id}}">
+<span class="time">{{time .Time}}</span>
+<span class="sender">{{.Sender}}</span>
+{{if .Text}}<span class="text">{{.Text}}</span>{{end}}
+{{if .Media}}{{if isImage .}}<div><img src="{{.Media}}" alt="{{.ContentType}}"></div>{{else}}<div><a href="{{.Media}}">{{.ContentType}}</a></div>{{end}}{{else if ne .ContentType "NONE"}}<span class="type">[{{.ContentType}}]</span>{{end}}
+{{with .Location}}<div class="location">{{.Title}} {{.Address}} ({{.Latitude}}, {{.Longitude}})</div>{{end}}
+{{if .ContentMetadata}}<table class="metadata">{{range $key, $value := .ContentMetadata}}<tr><td>{{$key}}</td><td>{{$value}}</td></tr>{{end}}</table>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
+
+func (self *ChatExporter) writeHtml(writer io.Writer, messages []*ExportedMessage) error {
+	return exportHtmlTemplate.Execute(writer, map[string]interface{}{
+		"Name": self.Entity.GetName(),
+		"Range": formatExportDate(self.Since, messages, 0) + " - " +
+			formatExportDate(self.Until, messages, -1),
+		"Messages": messages,
+	})
+}
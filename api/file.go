@@ -0,0 +1,50 @@
+package api
+
+import (
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func GetFileSize(message *prot.Message) (int64, bool) {
+	size, err := strconv.ParseInt(message.GetContentMetadata()["FILE_SIZE"], 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+func GetFileExpireTime(message *prot.Message) (time.Time, bool) {
+	timestamp, err := strconv.ParseInt(message.GetContentMetadata()["FILE_EXPIRE_TIMESTAMP"], 10, 64)
+	if err != nil || timestamp <= 0 {
+		return time.Time{}, false
+	}
+	return fromMilliseconds(timestamp), true
+}
+
+func IsFileExpired(message *prot.Message) bool {
+	expireTime, ok := GetFileExpireTime(message)
+	return ok && time.Now().After(expireTime)
+}
+
+func GetFileType(fileName string) string {
+	ext := strings.ToLower(path.Ext(fileName))
+	if ext == "" {
+		return "File"
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		if index := strings.Index(contentType, ";"); index >= 0 {
+			contentType = contentType[:index]
+		}
+		return contentType
+	}
+	return strings.ToUpper(ext[1:]) + " file"
+}
+
+func GetFileDownloadUrl(message *prot.Message) string {
+	return LINE_OBJECT_STORAGE_URL + message.GetId()
+}
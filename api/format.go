@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const LINE_STICON_URL = "http://stickershop.line-scdn.net/sticonshop/v1/sticon/"
+
+type TextTokenKind int
+
+const (
+	TEXT_PLAIN TextTokenKind = iota
+	TEXT_URL
+	TEXT_EMAIL
+	TEXT_PHONE
+)
+
+type TextToken struct {
+	Kind TextTokenKind
+	Text string
+	Uri  string
+}
+
+type Emoticon struct {
+	Start     int    `json:"S"`
+	End       int    `json:"E"`
+	ProductId string `json:"productId"`
+	SticonId  string `json:"sticonId"`
+	Version   int64  `json:"version"`
+}
+
+type TextSegment struct {
+	Text     string
+	Emoticon *Emoticon
+}
+
+var (
+	textLinkRegexp = regexp.MustCompile(`(?i)((?:https?://|www\.)[^\s<>"]+)` +
+		`|([a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,})` +
+		`|(\+\d[\d\-. ()]{5,}\d|\(0?\d{1,4}\) ?\d{3,4}[\- ]?\d{3,4}\b` +
+		`|\b0\d{1,4}[\- ]\d{2,4}[\- ]?\d{3,4}\b|\b0\d{8,10}\b)`)
+	phoneDigitRegexp = regexp.MustCompile(`\d`)
+)
+
+func trimUrl(url string) string {
+	url = strings.TrimRight(url, ".,;:!?'")
+	for _, pair := range []string{"()", "[]", "{}"} {
+		for strings.HasSuffix(url, pair[1:]) &&
+			strings.Count(url, pair[1:]) > strings.Count(url, pair[:1]) {
+			url = url[:len(url)-1]
+		}
+	}
+	return url
+}
+
+func TokenizeText(text string) []TextToken {
+	tokens := []TextToken{}
+	last := 0
+	for _, match := range textLinkRegexp.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[0], match[1]
+		var token TextToken
+		switch {
+		case match[2] >= 0:
+			token.Kind = TEXT_URL
+			token.Text = trimUrl(text[start:end])
+			end = start + len(token.Text)
+			token.Uri = token.Text
+			if strings.HasPrefix(strings.ToLower(token.Uri), "www.") {
+				token.Uri = "http://" + token.Uri
+			}
+		case match[4] >= 0:
+			token.Kind = TEXT_EMAIL
+			token.Text = text[start:end]
+			token.Uri = "mailto:" + token.Text
+		default:
+			token.Kind = TEXT_PHONE
+			token.Text = strings.TrimSpace(text[start:end])
+			digits := strings.Join(phoneDigitRegexp.FindAllString(token.Text, -1), "")
+			if strings.HasPrefix(token.Text, "+") {
+				if len(digits) < 7 || len(digits) > 15 {
+					continue
+				}
+				digits = "+" + digits
+			} else if len(digits) < 9 || len(digits) > 15 {
+				continue
+			}
+			token.Uri = "tel:" + digits
+		}
+		if start < last {
+			continue
+		}
+		if start > last {
+			tokens = append(tokens, TextToken{Kind: TEXT_PLAIN, Text: text[last:start]})
+		}
+		tokens = append(tokens, token)
+		last = end
+	}
+	if last < len(text) {
+		tokens = append(tokens, TextToken{Kind: TEXT_PLAIN, Text: text[last:]})
+	}
+	return tokens
+}
+
+func FormatTextMarkup(text string) string {
+	markup := ""
+	for _, token := range TokenizeText(text) {
+		if token.Kind == TEXT_PLAIN {
+			markup += html.EscapeString(token.Text)
+			continue
+		}
+		markup += "<a href=\"" + html.EscapeString(token.Uri) + "\">" + html.EscapeString(token.Text) + "</a>"
+	}
+	return markup
+}
+
+func GetEmoticons(message *prot.Message) []*Emoticon {
+	replace := message.GetContentMetadata()["REPLACE"]
+	if replace == "" {
+		return nil
+	}
+	var data struct {
+		Sticon struct {
+			Resources []*Emoticon `json:"resources"`
+		} `json:"sticon"`
+	}
+	if err := json.Unmarshal([]byte(replace), &data); err != nil {
+		return nil
+	}
+	return data.Sticon.Resources
+}
+
+func GetEmoticonUrl(emoticon *Emoticon) string {
+	return LINE_STICON_URL + emoticon.ProductId + "/iPhone/" + emoticon.SticonId + ".png"
+}
+
+func SplitEmoticons(message *prot.Message) []TextSegment {
+	text := utf16.Encode([]rune(message.GetText()))
+	segments := []TextSegment{}
+	last := 0
+	for _, emoticon := range GetEmoticons(message) {
+		if emoticon.Start < last || emoticon.End > len(text) || emoticon.Start >= emoticon.End {
+			continue
+		}
+		if emoticon.Start > last {
+			segments = append(segments, TextSegment{Text: string(utf16.Decode(text[last:emoticon.Start]))})
+		}
+		segments = append(segments, TextSegment{
+			Text:     string(utf16.Decode(text[emoticon.Start:emoticon.End])),
+			Emoticon: emoticon,
+		})
+		last = emoticon.End
+	}
+	if last < len(text) {
+		segments = append(segments, TextSegment{Text: string(utf16.Decode(text[last:]))})
+	}
+	return segments
+}
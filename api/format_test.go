@@ -0,0 +1,137 @@
+package api
+
+import (
+	"testing"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func TestTokenizeText(t *testing.T) {
+	cases := []struct {
+		text   string
+		tokens []TextToken
+	}{
+		{"see https://example.com/a_(b)). ok", []TextToken{
+			{TEXT_PLAIN, "see ", ""},
+			{TEXT_URL, "https://example.com/a_(b)", "https://example.com/a_(b)"},
+			{TEXT_PLAIN, "). ok", ""},
+		}},
+		{"www.line.me, bye", []TextToken{
+			{TEXT_URL, "www.line.me", "http://www.line.me"},
+			{TEXT_PLAIN, ", bye", ""},
+		}},
+		{"mail a.b@example.co.jp now", []TextToken{
+			{TEXT_PLAIN, "mail ", ""},
+			{TEXT_EMAIL, "a.b@example.co.jp", "mailto:a.b@example.co.jp"},
+			{TEXT_PLAIN, " now", ""},
+		}},
+		{"call +886 912-345-678.", []TextToken{
+			{TEXT_PLAIN, "call ", ""},
+			{TEXT_PHONE, "+886 912-345-678", "tel:+886912345678"},
+			{TEXT_PLAIN, ".", ""},
+		}},
+		{"0912345678", []TextToken{
+			{TEXT_PHONE, "0912345678", "tel:0912345678"},
+		}},
+		{"(02) 2345-6789", []TextToken{
+			{TEXT_PHONE, "(02) 2345-6789", "tel:0223456789"},
+		}},
+		{"03-1234-5678", []TextToken{
+			{TEXT_PHONE, "03-1234-5678", "tel:0312345678"},
+		}},
+	}
+	for _, c := range cases {
+		tokens := TokenizeText(c.text)
+		if len(tokens) != len(c.tokens) {
+			t.Errorf("TokenizeText(%q) = %+v; want %+v", c.text, tokens, c.tokens)
+			continue
+		}
+		for idx, token := range tokens {
+			if token != c.tokens[idx] {
+				t.Errorf("TokenizeText(%q)[%d] = %+v; want %+v", c.text, idx, token, c.tokens[idx])
+			}
+		}
+	}
+}
+
+func TestTokenizeTextIgnoresNumbers(t *testing.T) {
+	for _, text := range []string{
+		"2024-01-15",
+		"01-15-2024",
+		"at 12:30 or 23:59:59",
+		"order 1234567890",
+		"v1.2.3456",
+		"1234 5678",
+		"foo@bar",
+	} {
+		tokens := TokenizeText(text)
+		if len(tokens) != 1 || tokens[0].Kind != TEXT_PLAIN || tokens[0].Text != text {
+			t.Errorf("TokenizeText(%q) = %+v; want plain text", text, tokens)
+		}
+	}
+}
+
+func TestFormatTextMarkup(t *testing.T) {
+	cases := map[string]string{
+		"<b>bold</b> & 'q'":         "&lt;b&gt;bold&lt;/b&gt; &amp; &#39;q&#39;",
+		"http://a.com/?x=1&y=\"2\"": "<a href=\"http://a.com/?x=1&amp;y=\">http://a.com/?x=1&amp;y=</a>&#34;2&#34;",
+		"x<https://a.com>y":         "x&lt;<a href=\"https://a.com\">https://a.com</a>&gt;y",
+		"":                          "",
+	}
+	for text, expected := range cases {
+		if markup := FormatTextMarkup(text); markup != expected {
+			t.Errorf("FormatTextMarkup(%q) = %q; want %q", text, markup, expected)
+		}
+	}
+}
+
+func TestSplitEmoticons(t *testing.T) {
+	message := &prot.Message{
+		Text: "😀hi(a)(b)é",
+		ContentMetadata: map[string]string{
+			"REPLACE": `{"sticon":{"resources":[` +
+				`{"S":4,"E":7,"productId":"p1","sticonId":"s1","version":1},` +
+				`{"S":7,"E":10,"productId":"p2","sticonId":"s2","version":1},` +
+				`{"S":20,"E":30,"productId":"bad","sticonId":"bad","version":1}]}}`,
+		},
+	}
+	segments := SplitEmoticons(message)
+	expected := []struct {
+		text     string
+		sticonId string
+	}{
+		{"😀hi", ""},
+		{"(a)", "s1"},
+		{"(b)", "s2"},
+		{"é", ""},
+	}
+	if len(segments) != len(expected) {
+		t.Fatalf("SplitEmoticons = %+v; want %d segments", segments, len(expected))
+	}
+	for idx, segment := range segments {
+		sticonId := ""
+		if segment.Emoticon != nil {
+			sticonId = segment.Emoticon.SticonId
+		}
+		if segment.Text != expected[idx].text || sticonId != expected[idx].sticonId {
+			t.Errorf("segment %d = %q/%q; want %q/%q", idx, segment.Text, sticonId,
+				expected[idx].text, expected[idx].sticonId)
+		}
+	}
+	if url := GetEmoticonUrl(segments[1].Emoticon); url != LINE_STICON_URL+"p1/iPhone/s1.png" {
+		t.Errorf("GetEmoticonUrl = %q", url)
+	}
+}
+
+func TestSplitEmoticonsWithoutMetadata(t *testing.T) {
+	for _, meta := range []map[string]string{nil, {"REPLACE": "not json"}} {
+		message := &prot.Message{Text: "plain", ContentMetadata: meta}
+		if emoticons := GetEmoticons(message); len(emoticons) != 0 {
+			t.Errorf("GetEmoticons(%v) = %v; want none", meta, emoticons)
+		}
+		segments := SplitEmoticons(message)
+		if len(segments) != 1 || segments[0].Text != "plain" || segments[0].Emoticon != nil {
+			t.Errorf("SplitEmoticons(%v) = %+v; want the plain text", meta, segments)
+		}
+	}
+}
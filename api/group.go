@@ -0,0 +1,172 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func (self *LineClient) putGroup(group *prot.Group) *prot.Group {
+	for _, existing := range self.Groups {
+		if existing.GetId() == group.GetId() {
+			*existing = *group
+			return existing
+		}
+	}
+	self.Groups = append(self.Groups, group)
+	sort.Sort(self.Groups)
+	return group
+}
+
+func (self *LineClient) removeGroup(id string) {
+	for idx, group := range self.Groups {
+		if group.GetId() == id {
+			self.Groups = append(self.Groups[:idx], self.Groups[idx+1:]...)
+			return
+		}
+	}
+}
+
+func ContainsMid(mids string, mid string) bool {
+	for _, id := range strings.Split(mids, "\x1e") {
+		if id == mid {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *LineClient) putInvitedGroup(group *prot.Group) {
+	for _, existing := range self.Invited {
+		if existing.GetId() == group.GetId() {
+			*existing = *group
+			return
+		}
+	}
+	self.Invited = append(self.Invited, group)
+	sort.Sort(self.Invited)
+}
+
+func (self *LineClient) removeInvitedGroup(id string) {
+	for idx, group := range self.Invited {
+		if group.GetId() == id {
+			self.Invited = append(self.Invited[:idx], self.Invited[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (self *LineClient) RefreshInvitedGroup(id string) (*prot.Group, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	group, err := self.client.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	self.putInvitedGroup(group)
+	return group, nil
+}
+
+func (self *LineClient) RemoveInvitedGroup(id string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removeInvitedGroup(id)
+}
+
+func (self *LineClient) AcceptGroupInvitation(groupId string) (*prot.Group, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.AcceptGroupInvitation(0, groupId)
+	if err != nil {
+		return nil, err
+	}
+	self.removeInvitedGroup(groupId)
+	group, err := self.client.GetGroup(groupId)
+	if err != nil {
+		return nil, err
+	}
+	return self.putGroup(group), nil
+}
+
+func (self *LineClient) RejectGroupInvitation(groupId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.RejectGroupInvitation(0, groupId)
+	if err != nil {
+		return err
+	}
+	self.removeInvitedGroup(groupId)
+	return nil
+}
+
+func (self *LineClient) RefreshGroup(id string) (*prot.Group, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	group, err := self.client.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return self.putGroup(group), nil
+}
+
+func (self *LineClient) RemoveGroup(id string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removeGroup(id)
+}
+
+func (self *LineClient) CreateGroup(name string, contactIds []string) (*prot.Group, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	group, err := self.client.CreateGroup(0, name, contactIds)
+	if err != nil {
+		return nil, err
+	}
+	return self.putGroup(group), nil
+}
+
+func (self *LineClient) InviteIntoGroup(groupId string, contactIds []string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.InviteIntoGroup(0, groupId, contactIds)
+}
+
+func (self *LineClient) KickoutFromGroup(groupId string, contactIds []string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.KickoutFromGroup(0, groupId, contactIds)
+}
+
+func (self *LineClient) CancelGroupInvitation(groupId string, contactIds []string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.CancelGroupInvitation(0, groupId, contactIds)
+}
+
+func (self *LineClient) LeaveGroup(groupId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.LeaveGroup(0, groupId)
+	if err != nil {
+		return err
+	}
+	self.removeGroup(groupId)
+	return nil
+}
+
+func (self *LineClient) RenameGroup(groupId string, name string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	group, err := self.client.GetGroup(groupId)
+	if err != nil {
+		return err
+	}
+	group.Name = name
+	err = self.client.UpdateGroup(0, group)
+	if err != nil {
+		return err
+	}
+	self.putGroup(group)
+	return nil
+}
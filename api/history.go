@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+type HistoryStore struct {
+	DirPath string
+	lock    sync.Mutex
+}
+
+func NewHistoryStore(dirPath string) (*HistoryStore, error) {
+	err := os.MkdirAll(dirPath, os.FileMode(0700))
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryStore{DirPath: dirPath}, nil
+}
+
+func (self *HistoryStore) getFilePath(id string) string {
+	return path.Join(self.DirPath, id+".jsonl")
+}
+
+func (self *HistoryStore) load(id string) (MessageSlice, error) {
+	messages := make(MessageSlice, 0)
+	file, err := os.Open(self.getFilePath(id))
+	if os.IsNotExist(err) {
+		return messages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	jsonDecoder := json.NewDecoder(bufio.NewReader(file))
+	for jsonDecoder.More() {
+		message := &prot.Message{}
+		err = jsonDecoder.Decode(message)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (self *HistoryStore) Load(id string) ([]*prot.Message, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	messages, err := self.load(id)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(messages)
+	return messages, nil
+}
+
+func (self *HistoryStore) Merge(id string, messages []*prot.Message) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	stored, err := self.load(id)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool)
+	for _, message := range stored {
+		known[message.GetId()] = true
+	}
+	added := 0
+	for _, message := range messages {
+		if known[message.GetId()] {
+			continue
+		}
+		known[message.GetId()] = true
+		stored = append(stored, message)
+		added += 1
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	sort.Sort(stored)
+
+	filePath := self.getFilePath(id)
+	tmpFilePath := filePath + ".tmp"
+	file, err := os.OpenFile(tmpFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return 0, err
+	}
+	writer := bufio.NewWriter(file)
+	jsonEncoder := json.NewEncoder(writer)
+	for _, message := range stored {
+		err = jsonEncoder.Encode(message)
+		if err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	err = file.Close()
+	if err != nil {
+		return 0, err
+	}
+	return added, os.Rename(tmpFilePath, filePath)
+}
+
+func (self *HistoryStore) Remove(id string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := os.Remove(self.getFilePath(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
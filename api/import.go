@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const (
+	IMPORTED_MID_PREFIX      = "imported:"
+	IMPORTED_ID_PREFIX       = "imported-"
+	IMPORTED_SENDER_META     = "IMPORTED_SENDER"
+	IMPORTED_PLACEHOLDER_KEY = "IMPORTED_PLACEHOLDER"
+)
+
+var (
+	historyDateRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`^(\d{4})[/.\-](\d{1,2})[/.\-](\d{1,2})(?:\s*\(\S+\)|\s+(?i:mon|tue|wed|thu|fri|sat|sun)\S*)?$`),
+		regexp.MustCompile(`^\S+,\s*(\d{1,2})/(\d{1,2})/(\d{4})$`),
+	}
+	historyLineRegex = regexp.MustCompile(`^(\d{1,2}):(\d{2})\s*([AaPp][Mm])?\t([^\t]*)\t?(.*)$`)
+
+	historyPlaceholders = map[string]prot.ContentType{
+		"[Sticker]":       prot.ContentType_STICKER,
+		"[Photo]":         prot.ContentType_IMAGE,
+		"[Video]":         prot.ContentType_VIDEO,
+		"[Voice message]": prot.ContentType_AUDIO,
+		"[Audio]":         prot.ContentType_AUDIO,
+		"[File]":          prot.ContentType_FILE,
+		"[Location]":      prot.ContentType_LOCATION,
+		"[Contact]":       prot.ContentType_CONTACT,
+	}
+)
+
+type HistoryEntry struct {
+	Time        time.Time
+	Sender      string
+	Text        string
+	ContentType prot.ContentType
+	Placeholder string
+}
+
+func parseHistoryDate(line string) (time.Time, bool) {
+	for idx, regex := range historyDateRegexes {
+		match := regex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		year, month, day := match[1], match[2], match[3]
+		if idx == 1 {
+			year, month, day = match[3], match[1], match[2]
+		}
+		y, _ := strconv.Atoi(year)
+		m, _ := strconv.Atoi(month)
+		d, _ := strconv.Atoi(day)
+		if m < 1 || m > 12 || d < 1 || d > 31 {
+			return time.Time{}, false
+		}
+		return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.Local), true
+	}
+	return time.Time{}, false
+}
+
+func ParseLineHistory(reader io.Reader) ([]*HistoryEntry, error) {
+	entries := make([]*HistoryEntry, 0)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var date time.Time
+	var last *HistoryEntry
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if newDate, ok := parseHistoryDate(strings.TrimSpace(line)); ok {
+			date = newDate
+			last = nil
+			continue
+		}
+		match := historyLineRegex.FindStringSubmatch(line)
+		if match == nil || date.IsZero() {
+			if last != nil {
+				last.Text += "\n" + line
+			}
+			continue
+		}
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		switch strings.ToUpper(match[3]) {
+		case "AM":
+			if hour == 12 {
+				hour = 0
+			}
+		case "PM":
+			if hour != 12 {
+				hour += 12
+			}
+		}
+		last = &HistoryEntry{
+			Time:   date.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute),
+			Sender: match[4],
+			Text:   match[5],
+		}
+		entries = append(entries, last)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("No messages found in chat history.")
+	}
+	for _, entry := range entries {
+		entry.Text = strings.TrimSpace(entry.Text)
+		if len(entry.Text) >= 2 && strings.HasPrefix(entry.Text, "\"") && strings.HasSuffix(entry.Text, "\"") {
+			entry.Text = strings.Replace(entry.Text[1:len(entry.Text)-1], "\"\"", "\"", -1)
+		}
+		if contentType, ok := historyPlaceholders[entry.Text]; ok {
+			entry.ContentType = contentType
+			entry.Placeholder = entry.Text
+			entry.Text = ""
+		}
+	}
+	return entries, nil
+}
+
+func hashHistoryKey(parts ...string) string {
+	sum := md5.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func historyMatchKey(from string, createdTime time.Time, contentType prot.ContentType, text string) string {
+	return from + "\x00" + createdTime.Truncate(time.Minute).Format(time.RFC3339) + "\x00" +
+		contentType.String() + "\x00" + text
+}
+
+func (self *LineClient) getMidByDisplayName(name string) string {
+	if self.Profile != nil && self.Profile.GetDisplayName() == name {
+		return self.Profile.GetMid()
+	}
+	for _, contact := range self.Contacts {
+		if contact.GetDisplayName() == name || contact.GetDisplayNameOverridden() == name {
+			return contact.GetMid()
+		}
+	}
+	return IMPORTED_MID_PREFIX + hashHistoryKey(name)
+}
+
+func (self *LineClient) ConvertHistoryEntries(entity LineEntity, entries []*HistoryEntry) []*prot.Message {
+	mid := self.Profile.GetMid()
+	messages := make([]*prot.Message, 0, len(entries))
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		from := self.getMidByDisplayName(entry.Sender)
+		message := &prot.Message{
+			From:        from,
+			To:          entity.GetId(),
+			CreatedTime: entry.Time.UnixNano() / int64(time.Millisecond),
+			Text:        entry.Text,
+			ContentType: entry.ContentType,
+			ContentMetadata: map[string]string{
+				IMPORTED_SENDER_META: entry.Sender,
+			},
+		}
+		if entry.Placeholder != "" {
+			message.ContentMetadata[IMPORTED_PLACEHOLDER_KEY] = entry.Placeholder
+		}
+		switch entity.(type) {
+		case *LineGroupWrapper:
+			message.ToType = prot.MIDType_GROUP
+		case *LineRoomWrapper:
+			message.ToType = prot.MIDType_ROOM
+		default:
+			if from != mid {
+				message.To = mid
+			}
+		}
+		key := historyMatchKey(entry.Sender, entry.Time, entry.ContentType, entry.Text)
+		counts[key] += 1
+		message.Id = IMPORTED_ID_PREFIX + hashHistoryKey(key, strconv.Itoa(counts[key]))
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func (self *LineClient) ImportHistory(store *HistoryStore, entity LineEntity, entries []*HistoryEntry) (int, error) {
+	messages := self.ConvertHistoryEntries(entity, entries)
+	since := MessageTime(messages[0])
+	until := since
+	for _, message := range messages {
+		createdTime := MessageTime(message)
+		if createdTime.Before(since) {
+			since = createdTime
+		}
+		if createdTime.After(until) {
+			until = createdTime
+		}
+	}
+	since = since.Add(-time.Minute)
+	until = until.Add(time.Minute)
+
+	serverKeys := make(map[string]int)
+	messageBox, err := self.GetMessageBox(entity.GetId())
+	if err != nil {
+		return 0, err
+	}
+	serverMessages, err := self.GetMessagesBetween(messageBox, since, until)
+	if err != nil {
+		return 0, err
+	}
+	for _, message := range serverMessages {
+		key := historyMatchKey(message.GetFrom(), MessageTime(message),
+			message.GetContentType(), message.GetText())
+		serverKeys[key] += 1
+	}
+
+	merged := make([]*prot.Message, 0, len(messages))
+	for _, message := range messages {
+		key := historyMatchKey(message.GetFrom(), MessageTime(message),
+			message.GetContentType(), message.GetText())
+		if serverKeys[key] > 0 {
+			serverKeys[key] -= 1
+			continue
+		}
+		merged = append(merged, message)
+	}
+	return store.Merge(entity.GetId(), merged)
+}
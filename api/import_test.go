@@ -0,0 +1,74 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func TestParseHistoryDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"2014/05/03(Sat)":   time.Date(2014, 5, 3, 0, 0, 0, 0, time.Local),
+		"2014.5.3 Saturday": time.Date(2014, 5, 3, 0, 0, 0, 0, time.Local),
+		"2014-05-03":        time.Date(2014, 5, 3, 0, 0, 0, 0, time.Local),
+		"Sat, 05/03/2014":   time.Date(2014, 5, 3, 0, 0, 0, 0, time.Local),
+	}
+	for line, expected := range cases {
+		date, ok := parseHistoryDate(line)
+		if !ok || !date.Equal(expected) {
+			t.Errorf("parseHistoryDate(%q) = %v, %v; want %v", line, date, ok, expected)
+		}
+	}
+	for _, line := range []string{"2014/13/03", "2014/05/32", "hello", "12:30\tAlice\thi", "2020/01/02 foo"} {
+		if _, ok := parseHistoryDate(line); ok {
+			t.Errorf("parseHistoryDate(%q) should fail", line)
+		}
+	}
+}
+
+func TestParseLineHistory(t *testing.T) {
+	history := "\ufeff[LINE] Chat history with Alice\r\n" +
+		"Saved on: 2014/05/04 10:00\r\n" +
+		"\r\n" +
+		"2014/05/03(Sat)\r\n" +
+		"09:05\tAlice\tGood morning\r\n" +
+		"2020/01/02 deadline\r\n" +
+		"12:00 AM\tBob\tMidnight\r\n" +
+		"12:30 PM\tBob\t\"First line\r\n" +
+		"second \"\"quoted\"\" line\"\r\n" +
+		"11:59 PM\tAlice\t[Sticker]\r\n" +
+		"Sun, 05/04/2014\r\n" +
+		"7:01\tAlice\tNext day\r\n"
+	entries, err := ParseLineHistory(strings.NewReader(history))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []HistoryEntry{
+		{Time: time.Date(2014, 5, 3, 9, 5, 0, 0, time.Local), Sender: "Alice", Text: "Good morning\n2020/01/02 deadline"},
+		{Time: time.Date(2014, 5, 3, 0, 0, 0, 0, time.Local), Sender: "Bob", Text: "Midnight"},
+		{Time: time.Date(2014, 5, 3, 12, 30, 0, 0, time.Local), Sender: "Bob",
+			Text: "First line\nsecond \"quoted\" line"},
+		{Time: time.Date(2014, 5, 3, 23, 59, 0, 0, time.Local), Sender: "Alice",
+			ContentType: prot.ContentType_STICKER, Placeholder: "[Sticker]"},
+		{Time: time.Date(2014, 5, 4, 7, 1, 0, 0, time.Local), Sender: "Alice", Text: "Next day"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(expected))
+	}
+	for idx, entry := range entries {
+		want := expected[idx]
+		if !entry.Time.Equal(want.Time) || entry.Sender != want.Sender || entry.Text != want.Text ||
+			entry.ContentType != want.ContentType || entry.Placeholder != want.Placeholder {
+			t.Errorf("entry %d = %+v; want %+v", idx, *entry, want)
+		}
+	}
+}
+
+func TestParseLineHistoryEmpty(t *testing.T) {
+	_, err := ParseLineHistory(strings.NewReader("[LINE] Chat history\nnothing here\n"))
+	if err == nil {
+		t.Error("expected an error for history without messages")
+	}
+}
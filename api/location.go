@@ -0,0 +1,27 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func FormatCoordinates(location *prot.Location) string {
+	return fmt.Sprintf("%.6f, %.6f", location.GetLatitude(), location.GetLongitude())
+}
+
+func GetGeoUri(location *prot.Location) string {
+	coordinates := fmt.Sprintf("%.6f,%.6f", location.GetLatitude(), location.GetLongitude())
+	uri := "geo:" + coordinates
+	if location.GetTitle() != "" {
+		uri += "?q=" + coordinates + "(" + url.QueryEscape(location.GetTitle()) + ")"
+	}
+	return uri
+}
+
+func GetOpenStreetMapUrl(location *prot.Location) string {
+	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.6f&mlon=%.6f#map=16/%.6f/%.6f",
+		location.GetLatitude(), location.GetLongitude(),
+		location.GetLatitude(), location.GetLongitude())
+}
@@ -57,6 +57,7 @@ func (self *LineClient) AuthTokenLogin(authToken string) error {
 	self.header.Add("X-Line-Access", authToken)
 	httpTrans.SetHeader("X-Line-Access", authToken)
 	self.AuthToken = authToken
+	self.LoginErrors = nil
 
 	_, err := self.RefreshRevision()
 	if err != nil {
@@ -76,10 +77,24 @@ func (self *LineClient) AuthTokenLogin(authToken string) error {
 		return err
 	}
 
+	_, err = self.RefreshBlockedContacts()
+	if err != nil {
+		self.LoginErrors = append(self.LoginErrors, err)
+	}
+
+	_, err = self.RefreshHiddenContacts()
+	if err != nil {
+		self.LoginErrors = append(self.LoginErrors, err)
+	}
+
 	_, err = self.RefreshRooms()
 	if err != nil {
 		return err
 	}
+	err = self.SyncServerTime()
+	if err != nil {
+		self.LoginErrors = append(self.LoginErrors, err)
+	}
 	return nil
 }
 
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const SNIFF_LENGTH = 512
+
+var preferredExtensions = map[string]string{
+	"image/jpeg":         ".jpg",
+	"image/png":          ".png",
+	"image/gif":          ".gif",
+	"image/webp":         ".webp",
+	"image/bmp":          ".bmp",
+	"video/mp4":          ".mp4",
+	"video/3gpp":         ".3gp",
+	"video/quicktime":    ".mov",
+	"video/webm":         ".webm",
+	"audio/mp4":          ".m4a",
+	"audio/aac":          ".aac",
+	"audio/amr":          ".amr",
+	"audio/mpeg":         ".mp3",
+	"audio/ogg":          ".ogg",
+	"audio/wave":         ".wav",
+	"application/ogg":    ".ogg",
+	"application/pdf":    ".pdf",
+	"application/zip":    ".zip",
+	"application/x-gzip": ".gz",
+	"text/plain":         ".txt",
+	"text/html":          ".html",
+}
+
+func SniffContentType(data []byte) string {
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		brand := string(data[8:12])
+		switch {
+		case strings.HasPrefix(brand, "M4A"):
+			return "audio/mp4"
+		case strings.HasPrefix(brand, "3gp"):
+			return "video/3gpp"
+		case brand == "qt  ":
+			return "video/quicktime"
+		}
+		return "video/mp4"
+	}
+	if bytes.HasPrefix(data, []byte("#!AMR")) {
+		return "audio/amr"
+	}
+	if len(data) >= 2 && data[0] == 0xff && (data[1]&0xf6) == 0xf0 {
+		return "audio/aac"
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return contentType
+}
+
+func SniffFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data := make([]byte, SNIFF_LENGTH)
+	n, err := file.Read(data)
+	if err != nil && n == 0 {
+		return "", err
+	}
+	return SniffContentType(data[:n]), nil
+}
+
+func ExtensionByType(contentType string) string {
+	contentType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := preferredExtensions[contentType]; ok {
+		return ext
+	}
+	if contentType == "application/octet-stream" {
+		return ""
+	}
+	exts, err := mime.ExtensionsByType(contentType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+func getDefaultExtension(contentType prot.ContentType) string {
+	switch contentType {
+	case prot.ContentType_IMAGE:
+		return ".jpg"
+	case prot.ContentType_VIDEO:
+		return ".mp4"
+	case prot.ContentType_AUDIO:
+		return ".m4a"
+	case prot.ContentType_STICKER:
+		return ".png"
+	}
+	return ""
+}
+
+func GetMessageFileName(message *prot.Message) string {
+	meta := message.GetContentMetadata()
+	for _, key := range []string{"FILE_NAME", "OBS_FILE_NAME", "DOWNLOAD_FILE_NAME"} {
+		if name := path.Base(meta[key]); meta[key] != "" && name != "/" && name != "." {
+			return name
+		}
+	}
+	if message.GetContentType() == prot.ContentType_STICKER {
+		return meta["STKID"] + ".png"
+	}
+	return message.GetId() + getDefaultExtension(message.GetContentType())
+}
+
+func AddFileExtension(filePath string, contentType string) (string, error) {
+	if path.Ext(filePath) != "" {
+		return filePath, nil
+	}
+	ext := ExtensionByType(contentType)
+	if ext == "" {
+		sniffed, err := SniffFile(filePath)
+		if err != nil {
+			return filePath, err
+		}
+		ext = ExtensionByType(sniffed)
+	}
+	if ext == "" {
+		return filePath, nil
+	}
+	newFilePath := filePath + ext
+	if _, err := os.Stat(newFilePath); err == nil {
+		return filePath, nil
+	}
+	err := os.Rename(filePath, newFilePath)
+	if err != nil {
+		return filePath, err
+	}
+	return newFilePath, nil
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"html"
+	"sync"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	NOTIFICATIONS_NAME               = "org.freedesktop.Notifications"
+	NOTIFICATIONS_PATH               = "/org/freedesktop/Notifications"
+	NOTIFICATION_APP_NAME            = "Goline"
+	NOTIFICATION_ACTION_OPEN         = "default"
+	NOTIFICATION_ACTION_REPLY        = "reply"
+	NOTIFICATION_ACTION_INLINE_REPLY = "inline-reply"
+	NOTIFICATIONS_MATCH_RULE         = "type='signal',path='" + NOTIFICATIONS_PATH + "',interface='" + NOTIFICATIONS_NAME + "'"
+)
+
+type Notification struct {
+	ChatId  string
+	Summary string
+	Body    string
+	Icon    string
+}
+
+type Notifier struct {
+	OnOpen  func(chatId string)
+	OnReply func(chatId string, text string)
+
+	conn          *dbus.Conn
+	object        dbus.BusObject
+	capabilities  map[string]bool
+	notifications map[uint32]string
+	chats         map[string]uint32
+	lock          sync.Mutex
+}
+
+func NewNotifier() (*Notifier, error) {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.Auth(nil)
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	notifier, err := NewNotifierWithConn(conn, NOTIFICATIONS_NAME)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return notifier, nil
+}
+
+func NewNotifierWithConn(conn *dbus.Conn, dest string) (*Notifier, error) {
+	notifier := &Notifier{
+		conn:          conn,
+		object:        conn.Object(dest, NOTIFICATIONS_PATH),
+		capabilities:  make(map[string]bool),
+		notifications: make(map[uint32]string),
+		chats:         make(map[string]uint32),
+	}
+	var capabilities []string
+	err := notifier.object.Call(NOTIFICATIONS_NAME+".GetCapabilities", 0).Store(&capabilities)
+	if err != nil {
+		return nil, err
+	}
+	for _, capability := range capabilities {
+		notifier.capabilities[capability] = true
+	}
+
+	call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, NOTIFICATIONS_MATCH_RULE)
+	if call.Err != nil {
+		return nil, call.Err
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go notifier.listen(signals)
+	return notifier, nil
+}
+
+func (self *Notifier) Shutdown() {
+	self.conn.BusObject().Call("org.freedesktop.DBus.RemoveMatch", 0, NOTIFICATIONS_MATCH_RULE)
+	self.conn.Close()
+}
+
+func (self *Notifier) Notify(notification *Notification) error {
+	self.lock.Lock()
+	replacesId := self.chats[notification.ChatId]
+	self.lock.Unlock()
+
+	body := notification.Body
+	if self.capabilities["body-markup"] {
+		body = html.EscapeString(body)
+	}
+	actions := []string{}
+	if self.capabilities["actions"] {
+		actions = append(actions, NOTIFICATION_ACTION_OPEN, "Open")
+		if self.capabilities[NOTIFICATION_ACTION_INLINE_REPLY] {
+			actions = append(actions, NOTIFICATION_ACTION_INLINE_REPLY, "Reply")
+		} else {
+			actions = append(actions, NOTIFICATION_ACTION_REPLY, "Reply")
+		}
+	}
+	hints := map[string]dbus.Variant{
+		"category":      dbus.MakeVariant("im.received"),
+		"desktop-entry": dbus.MakeVariant("goline"),
+	}
+	if notification.Icon != "" {
+		hints["image-path"] = dbus.MakeVariant(notification.Icon)
+	}
+
+	var id uint32
+	err := self.object.Call(NOTIFICATIONS_NAME+".Notify", 0,
+		NOTIFICATION_APP_NAME, replacesId, notification.Icon,
+		notification.Summary, body, actions, hints, int32(-1)).Store(&id)
+	if err != nil {
+		return err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if replacesId != 0 && replacesId != id {
+		delete(self.notifications, replacesId)
+	}
+	self.notifications[id] = notification.ChatId
+	self.chats[notification.ChatId] = id
+	return nil
+}
+
+func (self *Notifier) Close(chatId string) {
+	self.lock.Lock()
+	id, ok := self.chats[chatId]
+	self.lock.Unlock()
+	if !ok {
+		return
+	}
+	self.object.Call(NOTIFICATIONS_NAME+".CloseNotification", 0, id)
+}
+
+func (self *Notifier) forget(id uint32) string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	chatId, ok := self.notifications[id]
+	if !ok {
+		return ""
+	}
+	delete(self.notifications, id)
+	if self.chats[chatId] == id {
+		delete(self.chats, chatId)
+	}
+	return chatId
+}
+
+func (self *Notifier) lookup(id uint32) string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.notifications[id]
+}
+
+func (self *Notifier) listen(signals chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		id, ok := signal.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+		switch signal.Name {
+		case NOTIFICATIONS_NAME + ".ActionInvoked":
+			action, _ := signal.Body[1].(string)
+			chatId := self.lookup(id)
+			if chatId == "" {
+				continue
+			}
+			switch action {
+			case NOTIFICATION_ACTION_OPEN:
+				if self.OnOpen != nil {
+					self.OnOpen(chatId)
+				}
+			case NOTIFICATION_ACTION_REPLY:
+				if self.OnReply != nil {
+					self.OnReply(chatId, "")
+				}
+			}
+		case NOTIFICATIONS_NAME + ".NotificationReplied":
+			text, _ := signal.Body[1].(string)
+			chatId := self.lookup(id)
+			if chatId != "" && text != "" && self.OnReply != nil {
+				self.OnReply(chatId, text)
+			}
+		case NOTIFICATIONS_NAME + ".NotificationClosed":
+			self.forget(id)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+const testNotificationsName = "org.goline.TestNotifications"
+
+type notifyCall struct {
+	AppName    string
+	ReplacesId uint32
+	Icon       string
+	Summary    string
+	Body       string
+	Actions    []string
+	Hints      map[string]dbus.Variant
+	Timeout    int32
+}
+
+type fakeNotifications struct {
+	capabilities []string
+	calls        []notifyCall
+	closed       []uint32
+	lastId       uint32
+	lock         sync.Mutex
+}
+
+func (self *fakeNotifications) GetCapabilities() ([]string, *dbus.Error) {
+	return self.capabilities, nil
+}
+
+func (self *fakeNotifications) Notify(appName string, replacesId uint32, icon string,
+	summary string, body string, actions []string, hints map[string]dbus.Variant,
+	timeout int32) (uint32, *dbus.Error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.calls = append(self.calls, notifyCall{appName, replacesId, icon, summary, body, actions, hints, timeout})
+	if replacesId != 0 {
+		return replacesId, nil
+	}
+	self.lastId += 1
+	return self.lastId, nil
+}
+
+func (self *fakeNotifications) CloseNotification(id uint32) *dbus.Error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.closed = append(self.closed, id)
+	return nil
+}
+
+func startTestBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+	dir, err := os.MkdirTemp("", "goline-dbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1",
+		"--address=unix:path="+path.Join(dir, "bus"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skip("failed to start dbus-daemon: ", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func dialTestBus(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func setupTestNotifier(t *testing.T, capabilities []string) (*Notifier, *fakeNotifications, *dbus.Conn) {
+	address := startTestBus(t)
+	server := dialTestBus(t, address)
+	fake := &fakeNotifications{capabilities: capabilities}
+	if err := server.Export(fake, NOTIFICATIONS_PATH, NOTIFICATIONS_NAME); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := server.RequestName(testNotificationsName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatal("failed to own test name: ", err)
+	}
+	notifier, err := NewNotifierWithConn(dialTestBus(t, address), testNotificationsName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifier, fake, server
+}
+
+func TestNotifierNotify(t *testing.T) {
+	notifier, fake, _ := setupTestNotifier(t, []string{"actions", "body-markup"})
+	notification := &Notification{ChatId: "c1", Summary: "Alice", Body: "<b>hi</b> & bye", Icon: "/tmp/a.png"}
+	if err := notifier.Notify(notification); err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(notification); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if len(fake.calls) != 2 {
+		t.Fatalf("got %d Notify calls, want 2", len(fake.calls))
+	}
+	call := fake.calls[0]
+	if call.AppName != NOTIFICATION_APP_NAME || call.ReplacesId != 0 || call.Icon != "/tmp/a.png" ||
+		call.Summary != "Alice" || call.Body != "&lt;b&gt;hi&lt;/b&gt; &amp; bye" || call.Timeout != -1 {
+		t.Errorf("unexpected Notify arguments: %+v", call)
+	}
+	expectedActions := []string{NOTIFICATION_ACTION_OPEN, "Open", NOTIFICATION_ACTION_REPLY, "Reply"}
+	if strings.Join(call.Actions, ",") != strings.Join(expectedActions, ",") {
+		t.Errorf("actions = %v; want %v", call.Actions, expectedActions)
+	}
+	if call.Hints["image-path"].Value() != "/tmp/a.png" || call.Hints["category"].Value() != "im.received" {
+		t.Errorf("unexpected hints: %v", call.Hints)
+	}
+	if fake.calls[1].ReplacesId != 1 {
+		t.Errorf("second notification replaces %d; want 1", fake.calls[1].ReplacesId)
+	}
+}
+
+func TestNotifierPlainBody(t *testing.T) {
+	notifier, fake, _ := setupTestNotifier(t, []string{"actions", NOTIFICATION_ACTION_INLINE_REPLY})
+	if err := notifier.Notify(&Notification{ChatId: "c1", Summary: "Alice", Body: "a < b"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	call := fake.calls[0]
+	if call.Body != "a < b" {
+		t.Errorf("body = %q; want unescaped body", call.Body)
+	}
+	if len(call.Actions) != 4 || call.Actions[2] != NOTIFICATION_ACTION_INLINE_REPLY {
+		t.Errorf("actions = %v; want inline reply", call.Actions)
+	}
+	if _, ok := call.Hints["image-path"]; ok {
+		t.Error("image-path hint set without an icon")
+	}
+}
+
+func TestNotifierActionRouting(t *testing.T) {
+	notifier, _, server := setupTestNotifier(t, []string{"actions"})
+	opened := make(chan string, 1)
+	replied := make(chan [2]string, 2)
+	notifier.OnOpen = func(chatId string) {
+		opened <- chatId
+	}
+	notifier.OnReply = func(chatId string, text string) {
+		replied <- [2]string{chatId, text}
+	}
+	for _, chatId := range []string{"c1", "c2"} {
+		if err := notifier.Notify(&Notification{ChatId: chatId, Summary: chatId}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emit := func(member string, values ...interface{}) {
+		if err := server.Emit(NOTIFICATIONS_PATH, NOTIFICATIONS_NAME+"."+member, values...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	emit("ActionInvoked", uint32(99), NOTIFICATION_ACTION_OPEN)
+	emit("ActionInvoked", uint32(2), NOTIFICATION_ACTION_OPEN)
+	select {
+	case chatId := <-opened:
+		if chatId != "c2" {
+			t.Errorf("opened %q; want c2", chatId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ActionInvoked was not routed to OnOpen")
+	}
+
+	emit("ActionInvoked", uint32(1), NOTIFICATION_ACTION_REPLY)
+	emit("NotificationReplied", uint32(1), "hello")
+	replies := map[[2]string]bool{}
+	for len(replies) < 2 {
+		select {
+		case reply := <-replied:
+			replies[reply] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("reply was not routed to OnReply")
+		}
+	}
+	if !replies[[2]string{"c1", ""}] || !replies[[2]string{"c1", "hello"}] {
+		t.Errorf("replies = %v; want reply action and inline reply for c1", replies)
+	}
+
+	emit("NotificationClosed", uint32(1), uint32(2))
+	deadline := time.Now().Add(5 * time.Second)
+	for notifier.lookup(1) != "" {
+		if time.Now().After(deadline) {
+			t.Fatal("NotificationClosed was not handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	emit("ActionInvoked", uint32(1), NOTIFICATION_ACTION_OPEN)
+	emit("ActionInvoked", uint32(2), NOTIFICATION_ACTION_OPEN)
+	select {
+	case chatId := <-opened:
+		if chatId != "c2" {
+			t.Errorf("closed notification still routed to %q", chatId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ActionInvoked was not routed after close")
+	}
+	select {
+	case chatId := <-opened:
+		t.Errorf("unexpected open of %q", chatId)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestNotifierShutdown(t *testing.T) {
+	notifier, _, server := setupTestNotifier(t, []string{"actions"})
+	opened := make(chan string, 1)
+	notifier.OnOpen = func(chatId string) {
+		opened <- chatId
+	}
+	if err := notifier.Notify(&Notification{ChatId: "c1", Summary: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	notifier.Shutdown()
+
+	err := server.Emit(NOTIFICATIONS_PATH, NOTIFICATIONS_NAME+".ActionInvoked", uint32(1), NOTIFICATION_ACTION_OPEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case chatId := <-opened:
+		t.Errorf("notification for %q routed after shutdown", chatId)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := notifier.Notify(&Notification{ChatId: "c1", Summary: "c1"}); err == nil {
+		t.Error("Notify succeeded after shutdown")
+	}
+}
@@ -1,9 +1,32 @@
 package api
 
 import (
+	"sort"
+	"time"
+
 	prot "github.com/carylorrk/goline/protocol"
 )
 
+type MessageSlice []*prot.Message
+
+func (s MessageSlice) Less(i, j int) bool {
+	return s[i].GetCreatedTime() < s[j].GetCreatedTime()
+}
+
+func (s MessageSlice) Swap(i, j int) {
+	tmp := s[i]
+	s[i] = s[j]
+	s[j] = tmp
+}
+
+func (s MessageSlice) Len() int {
+	return len(s)
+}
+
+func MessageTime(message *prot.Message) time.Time {
+	return time.Unix(0, message.GetCreatedTime()*int64(time.Millisecond))
+}
+
 func (self *LineClient) GetMessageBox(id string) (*prot.TMessageBox, error) {
 	self.lock.Lock()
 	defer self.lock.Unlock()
@@ -20,11 +43,56 @@ func (self *LineClient) GetRecentMessages(messageBox *prot.TMessageBox, count in
 	return self.client.GetRecentMessages(messageBox.GetId(), count)
 }
 
+func (self *LineClient) GetMessagesBetween(messageBox *prot.TMessageBox, since time.Time, until time.Time) ([]*prot.Message, error) {
+	step := int64(50)
+	messages := make(MessageSlice, 0)
+	for endSeq := messageBox.GetLastSeq(); endSeq > 0; endSeq -= step {
+		startSeq := endSeq - step + 1
+		if startSeq < 1 {
+			startSeq = 1
+		}
+		self.lock.Lock()
+		block, err := self.client.GetMessagesBySequenceNumber(
+			messageBox.GetChannelId(), messageBox.GetId(), startSeq, endSeq)
+		self.lock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		older := false
+		for _, message := range block {
+			createdTime := MessageTime(message)
+			if createdTime.Before(since) {
+				older = true
+				continue
+			}
+			if !until.IsZero() && createdTime.After(until) {
+				continue
+			}
+			messages = append(messages, message)
+		}
+		if older {
+			break
+		}
+	}
+	sort.Sort(messages)
+	return messages, nil
+}
+
 func (self *LineClient) SendText(id string, text string) (*prot.Message, error) {
+	return self.SendTextWithSeq(id, text, 0)
+}
+
+func (self *LineClient) SendTextWithSeq(id string, text string, reqSeq int32) (*prot.Message, error) {
 	self.lock.Lock()
 	defer self.lock.Unlock()
 	message := &prot.Message{To: id, Text: text}
-	return self.client.SendMessage(0, message)
+	return self.client.SendMessage(reqSeq, message)
+}
+
+func (self *LineClient) SendChatChecked(id string, lastMessageId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.SendChatChecked(0, id, lastMessageId)
 }
 
 func (self *LineClient) FetchNewOperations(count int32) ([]*prot.Operation, error) {
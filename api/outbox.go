@@ -0,0 +1,154 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+const LOCAL_ID_PREFIX = "local-"
+
+type SendState int
+
+const (
+	SEND_SENT SendState = iota
+	SEND_PENDING
+	SEND_FAILED
+)
+
+func (self SendState) String() string {
+	switch self {
+	case SEND_SENT:
+		return "Sent"
+	case SEND_PENDING:
+		return "Sending..."
+	case SEND_FAILED:
+		return "Failed to send"
+	}
+	return "Unknown"
+}
+
+type PendingMessage struct {
+	ReqSeq  int32
+	ChatId  string
+	Message *prot.Message
+	State   SendState
+	Err     error
+}
+
+type Outbox struct {
+	pending map[int32]*PendingMessage
+	lastSeq int32
+	lock    sync.Mutex
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{pending: make(map[int32]*PendingMessage)}
+}
+
+func IsLocalMessageId(id string) bool {
+	return strings.HasPrefix(id, LOCAL_ID_PREFIX)
+}
+
+func (self *Outbox) nextSeq() int32 {
+	self.lastSeq += 1
+	return self.lastSeq
+}
+
+func (self *Outbox) Add(from string, chatId string, text string, createdTime int64) *PendingMessage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	reqSeq := self.nextSeq()
+	pending := &PendingMessage{
+		ReqSeq: reqSeq,
+		ChatId: chatId,
+		Message: &prot.Message{
+			Id:          LOCAL_ID_PREFIX + strconv.Itoa(int(reqSeq)),
+			From:        from,
+			To:          chatId,
+			Text:        text,
+			CreatedTime: createdTime,
+		},
+		State: SEND_PENDING,
+	}
+	self.pending[reqSeq] = pending
+	return pending
+}
+
+func (self *Outbox) GetState(localId string) (SendState, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, pending := range self.pending {
+		if pending.Message.GetId() == localId {
+			return pending.State, true
+		}
+	}
+	return SEND_SENT, false
+}
+
+func (self *Outbox) Retry(localId string) (int32, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for reqSeq, pending := range self.pending {
+		if pending.Message.GetId() != localId || pending.State != SEND_FAILED {
+			continue
+		}
+		delete(self.pending, reqSeq)
+		pending.ReqSeq = self.nextSeq()
+		pending.State = SEND_PENDING
+		pending.Err = nil
+		self.pending[pending.ReqSeq] = pending
+		return pending.ReqSeq, true
+	}
+	return 0, false
+}
+
+func (self *Outbox) Resolve(reqSeq int32, message *prot.Message) *PendingMessage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	pending := self.pending[reqSeq]
+	if pending == nil || message == nil ||
+		pending.Message.GetTo() != message.GetTo() ||
+		pending.Message.GetText() != message.GetText() {
+		return nil
+	}
+	delete(self.pending, reqSeq)
+	pending.State = SEND_SENT
+	return pending
+}
+
+func (self *Outbox) Fail(reqSeq int32, err error) *PendingMessage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	pending := self.pending[reqSeq]
+	if pending == nil || pending.State != SEND_PENDING {
+		return nil
+	}
+	pending.State = SEND_FAILED
+	pending.Err = err
+	return pending
+}
+
+func (self *Outbox) Remove(localId string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for reqSeq, pending := range self.pending {
+		if pending.Message.GetId() == localId && pending.State == SEND_FAILED {
+			delete(self.pending, reqSeq)
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Outbox) RemoveChat(chatId string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for reqSeq, pending := range self.pending {
+		if pending.ChatId == chatId {
+			delete(self.pending, reqSeq)
+		}
+	}
+}
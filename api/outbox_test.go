@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func TestOutboxAdd(t *testing.T) {
+	outbox := NewOutbox()
+	first := outbox.Add("me", "c1", "hello", 1000)
+	second := outbox.Add("me", "c1", "again", 2000)
+	if first.ReqSeq == second.ReqSeq || first.Message.GetId() == second.Message.GetId() {
+		t.Error("pending messages share a request sequence or local id")
+	}
+	if !IsLocalMessageId(first.Message.GetId()) {
+		t.Errorf("%q is not a local message id", first.Message.GetId())
+	}
+	if first.Message.GetFrom() != "me" || first.Message.GetTo() != "c1" ||
+		first.Message.GetText() != "hello" || first.Message.GetCreatedTime() != 1000 {
+		t.Errorf("unexpected local echo: %+v", first.Message)
+	}
+	if state, ok := outbox.GetState(first.Message.GetId()); !ok || state != SEND_PENDING {
+		t.Errorf("state = %v, %v; want pending", state, ok)
+	}
+}
+
+func TestOutboxResolve(t *testing.T) {
+	outbox := NewOutbox()
+	pending := outbox.Add("me", "c1", "hello", 1000)
+	mismatched := []*prot.Message{
+		nil,
+		{Id: "1", To: "c2", Text: "hello"},
+		{Id: "1", To: "c1", Text: "other"},
+	}
+	for _, message := range mismatched {
+		if outbox.Resolve(pending.ReqSeq, message) != nil {
+			t.Errorf("resolved with mismatched message %+v", message)
+		}
+	}
+	if outbox.Resolve(pending.ReqSeq+1, &prot.Message{Id: "1", To: "c1", Text: "hello"}) != nil {
+		t.Error("resolved an unknown request sequence")
+	}
+
+	resolved := outbox.Resolve(pending.ReqSeq, &prot.Message{Id: "1", To: "c1", Text: "hello"})
+	if resolved != pending || resolved.State != SEND_SENT {
+		t.Fatalf("resolve = %+v; want the sent pending message", resolved)
+	}
+	if _, ok := outbox.GetState(pending.Message.GetId()); ok {
+		t.Error("resolved message is still pending")
+	}
+	if outbox.Fail(pending.ReqSeq, errors.New("late failure")) != nil {
+		t.Error("resolved message failed afterwards")
+	}
+}
+
+func TestOutboxFailRetryRemove(t *testing.T) {
+	outbox := NewOutbox()
+	pending := outbox.Add("me", "c1", "hello", 1000)
+	localId := pending.Message.GetId()
+	if _, ok := outbox.Retry(localId); ok {
+		t.Error("retried a message that has not failed")
+	}
+	if outbox.Remove(localId) {
+		t.Error("removed a message that is still sending")
+	}
+
+	sendErr := errors.New("network down")
+	failed := outbox.Fail(pending.ReqSeq, sendErr)
+	if failed != pending || failed.State != SEND_FAILED || failed.Err != sendErr {
+		t.Fatalf("fail = %+v; want the failed pending message", failed)
+	}
+	if outbox.Fail(pending.ReqSeq, sendErr) != nil {
+		t.Error("failed the same message twice")
+	}
+
+	oldSeq := pending.ReqSeq
+	reqSeq, ok := outbox.Retry(localId)
+	if !ok || reqSeq == oldSeq || pending.State != SEND_PENDING || pending.Err != nil {
+		t.Fatalf("retry = %d, %v with state %v; want a new pending request", reqSeq, ok, pending.State)
+	}
+	if outbox.Resolve(oldSeq, &prot.Message{Id: "1", To: "c1", Text: "hello"}) != nil {
+		t.Error("resolved the retried message with its old request sequence")
+	}
+
+	outbox.Fail(reqSeq, sendErr)
+	if !outbox.Remove(localId) {
+		t.Fatal("failed message was not removed")
+	}
+	if _, ok := outbox.GetState(localId); ok {
+		t.Error("removed message is still tracked")
+	}
+}
+
+func TestOutboxRemoveChat(t *testing.T) {
+	outbox := NewOutbox()
+	sending := outbox.Add("me", "c1", "sending", 1000)
+	failed := outbox.Add("me", "c1", "failed", 2000)
+	other := outbox.Add("me", "c2", "other", 3000)
+	outbox.Fail(failed.ReqSeq, errors.New("network down"))
+
+	outbox.RemoveChat("c1")
+	for _, pending := range []*PendingMessage{sending, failed} {
+		if _, ok := outbox.GetState(pending.Message.GetId()); ok {
+			t.Errorf("%q still tracked after its chat closed", pending.Message.GetText())
+		}
+	}
+	if _, ok := outbox.GetState(other.Message.GetId()); !ok {
+		t.Error("message of another chat was dropped")
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func (self *LineClient) UpdateProfileAttribute(attr prot.ProfileAttribute, value string) (*prot.Profile, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.UpdateProfileAttribute(0, attr, value)
+	if err != nil {
+		return nil, err
+	}
+	self.Profile, err = self.client.GetProfile()
+	return self.Profile, err
+}
+
+func (self *LineClient) IsUseridAvailable(userid string) (bool, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.IsUseridAvailable(userid)
+}
+
+func (self *LineClient) RegisterUserid(userid string) (*prot.Profile, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ok, err := self.client.RegisterUserid(0, userid)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("LINE ID is not available.")
+	}
+	self.Profile, err = self.client.GetProfile()
+	return self.Profile, err
+}
+
+func (self *LineClient) uploadProfilePicture(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	params, err := json.Marshal(map[string]string{
+		"name": path.Base(filePath),
+		"oid":  self.Profile.GetMid(),
+		"type": "image",
+		"ver":  "1.0",
+	})
+	if err != nil {
+		return err
+	}
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	err = writer.WriteField("params", string(params))
+	if err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile("file", path.Base(filePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", LINE_PROFILE_UPLOAD_URL, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range *self.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res, err := HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return errors.New("Upload failed: " + res.Status)
+	}
+	return nil
+}
+
+func (self *LineClient) UpdateProfilePicture(filePath string) (*prot.Profile, error) {
+	err := self.uploadProfilePicture(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return self.RefreshProfile()
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"strings"
+	"sync"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func CompareMessageId(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+type ReadReceipts struct {
+	chats map[string]map[string]string
+	lock  sync.Mutex
+}
+
+func NewReadReceipts() *ReadReceipts {
+	return &ReadReceipts{chats: make(map[string]map[string]string)}
+}
+
+func (self *ReadReceipts) MarkRead(chatId, readerId, messageId string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	readers := self.chats[chatId]
+	if readers == nil {
+		readers = make(map[string]string)
+		self.chats[chatId] = readers
+	}
+	if CompareMessageId(messageId, readers[readerId]) <= 0 {
+		return false
+	}
+	readers[readerId] = messageId
+	return true
+}
+
+func (self *ReadReceipts) GetReadCount(chatId, messageId string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	count := 0
+	for _, lastRead := range self.chats[chatId] {
+		if CompareMessageId(lastRead, messageId) >= 0 {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (self *ReadReceipts) HandleOperation(ownId string, operation *prot.Operation) (string, bool) {
+	switch operation.GetTypeA1() {
+	case prot.OpType_NOTIFIED_READ_MESSAGE:
+		chatId := operation.GetParam1()
+		readerId := operation.GetParam2()
+		if readerId == ownId {
+			return chatId, false
+		}
+		if chatId == ownId {
+			chatId = readerId
+		}
+		return chatId, self.MarkRead(chatId, readerId, operation.GetParam3())
+	case prot.OpType_RECEIVE_MESSAGE_RECEIPT:
+		readerId := operation.GetParam1()
+		if readerId == ownId {
+			return readerId, false
+		}
+		lastRead := ""
+		for _, messageId := range strings.Split(operation.GetParam2(), "\x1e") {
+			if CompareMessageId(messageId, lastRead) > 0 {
+				lastRead = messageId
+			}
+		}
+		if lastRead == "" {
+			return readerId, false
+		}
+		return readerId, self.MarkRead(readerId, readerId, lastRead)
+	}
+	return "", false
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+type RichContent struct {
+	Title        string
+	Description  string
+	ThumbnailUrl string
+	Url          string
+}
+
+var (
+	richTitleKeys       = []string{"title", "TITLE", "ALT_TEXT", "altText", "POST_TITLE", "name"}
+	richDescriptionKeys = []string{"description", "DESCRIPTION", "subText", "SUB_TEXT", "text", "TEXT", "POST_TEXT"}
+	richThumbnailKeys   = []string{"previewUrl", "PREVIEW_URL", "thumbnailUrl", "THUMBNAIL_URL", "imageUrl", "IMAGE_URL", "iconUrl"}
+	richUrlKeys         = []string{"linkUri", "LINK_URI", "url", "URL", "linkUrl", "LINK_URL", "DOWNLOAD_URL", "POST_URL", "HOME_URL"}
+)
+
+func firstMetadata(meta map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(meta[key]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func GetRichContent(message *prot.Message) *RichContent {
+	meta := message.GetContentMetadata()
+	content := &RichContent{
+		Title:        firstMetadata(meta, richTitleKeys),
+		Description:  firstMetadata(meta, richDescriptionKeys),
+		ThumbnailUrl: firstMetadata(meta, richThumbnailKeys),
+		Url:          firstMetadata(meta, richUrlKeys),
+	}
+	if content.Description == "" {
+		content.Description = strings.TrimSpace(message.GetText())
+	}
+	if content.Title == "" {
+		content.Title = content.Description
+		content.Description = ""
+	}
+	if content.Title == "" {
+		content.Title = content.Url
+	}
+	return content
+}
+
+func IsWebUrl(rawurl string) bool {
+	if strings.HasPrefix(strings.TrimSpace(rawurl), "-") {
+		return false
+	}
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	return (scheme == "http" || scheme == "https") && parsed.Host != ""
+}
+
+func FormatMetadata(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = key + ": " + meta[key]
+	}
+	return strings.Join(lines, "\n")
+}
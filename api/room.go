@@ -0,0 +1,74 @@
+package api
+
+import (
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+func (self *LineClient) putRoom(room *prot.Room) *prot.Room {
+	for _, existing := range self.Rooms {
+		if existing.GetMid() == room.GetMid() {
+			*existing = *room
+			return existing
+		}
+	}
+	self.Rooms = append(self.Rooms, room)
+	return room
+}
+
+func (self *LineClient) removeRoom(id string) {
+	for idx, room := range self.Rooms {
+		if room.GetMid() == id {
+			self.Rooms = append(self.Rooms[:idx], self.Rooms[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (self *LineClient) RefreshRoom(id string) (*prot.Room, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	room, err := self.client.GetRoom(id)
+	if err != nil {
+		return nil, err
+	}
+	return self.putRoom(room), nil
+}
+
+func (self *LineClient) RemoveRoom(id string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removeRoom(id)
+}
+
+func (self *LineClient) CreateRoom(contactIds []string) (*prot.Room, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	room, err := self.client.CreateRoom(0, contactIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(room.GetContacts()) == 0 {
+		fullRoom, err := self.client.GetRoom(room.GetMid())
+		if err == nil {
+			room = fullRoom
+		}
+	}
+	return self.putRoom(room), nil
+}
+
+func (self *LineClient) InviteIntoRoom(roomId string, contactIds []string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.InviteIntoRoom(0, roomId, contactIds)
+}
+
+func (self *LineClient) LeaveRoom(roomId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.LeaveRoom(0, roomId)
+	if err != nil {
+		return err
+	}
+	self.removeRoom(roomId)
+	return nil
+}
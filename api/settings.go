@@ -0,0 +1,114 @@
+package api
+
+import (
+	"strconv"
+
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+type SettingsItem struct {
+	Attribute prot.SettingsAttribute
+	Name      string
+	Get       func(settings *prot.Settings) bool
+	Set       func(settings *prot.Settings, value bool)
+}
+
+var NotificationSettings = []*SettingsItem{
+	{prot.SettingsAttribute_NOTIFICATION_ENABLE, "Enable notifications",
+		func(s *prot.Settings) bool { return s.NotificationEnable },
+		func(s *prot.Settings, v bool) { s.NotificationEnable = v }},
+	{prot.SettingsAttribute_NOTIFICATION_NEW_MESSAGE, "New messages",
+		func(s *prot.Settings) bool { return s.NotificationNewMessage },
+		func(s *prot.Settings, v bool) { s.NotificationNewMessage = v }},
+	{prot.SettingsAttribute_NOTIFICATION_GROUP_INVITATION, "Group invitations",
+		func(s *prot.Settings) bool { return s.NotificationGroupInvitation },
+		func(s *prot.Settings, v bool) { s.NotificationGroupInvitation = v }},
+	{prot.SettingsAttribute_NOTIFICATION_SHOW_MESSAGE, "Show message previews",
+		func(s *prot.Settings) bool { return s.NotificationShowMessage },
+		func(s *prot.Settings, v bool) { s.NotificationShowMessage = v }},
+	{prot.SettingsAttribute_NOTIFICATION_INCOMING_CALL, "Incoming calls",
+		func(s *prot.Settings) bool { return s.NotificationIncomingCall },
+		func(s *prot.Settings, v bool) { s.NotificationIncomingCall = v }},
+	{prot.SettingsAttribute_NOTIFICATION_DISABLED_WITH_SUB, "Disable on phone while logged in here",
+		func(s *prot.Settings) bool { return s.NotificationDisabledWithSub },
+		func(s *prot.Settings, v bool) { s.NotificationDisabledWithSub = v }},
+}
+
+var PrivacySettings = []*SettingsItem{
+	{prot.SettingsAttribute_PRIVACY_SYNC_CONTACTS, "Auto add friends from address book",
+		func(s *prot.Settings) bool { return s.PrivacySyncContacts },
+		func(s *prot.Settings, v bool) { s.PrivacySyncContacts = v }},
+	{prot.SettingsAttribute_PRIVACY_SEARCH_BY_PHONE_NUMBER, "Allow others to add me by phone number",
+		func(s *prot.Settings) bool { return s.PrivacySearchByPhoneNumber },
+		func(s *prot.Settings, v bool) { s.PrivacySearchByPhoneNumber = v }},
+	{prot.SettingsAttribute_PRIVACY_SEARCH_BY_USERID, "Allow others to add me by LINE ID",
+		func(s *prot.Settings) bool { return s.PrivacySearchByUserid },
+		func(s *prot.Settings, v bool) { s.PrivacySearchByUserid = v }},
+	{prot.SettingsAttribute_PRIVACY_SEARCH_BY_EMAIL, "Allow others to add me by email",
+		func(s *prot.Settings) bool { return s.PrivacySearchByEmail },
+		func(s *prot.Settings, v bool) { s.PrivacySearchByEmail = v }},
+	{prot.SettingsAttribute_PRIVACY_ALLOW_SECONDARY_DEVICE_LOGIN, "Allow login from other devices",
+		func(s *prot.Settings) bool { return s.PrivacyAllowSecondaryDeviceLogin },
+		func(s *prot.Settings, v bool) { s.PrivacyAllowSecondaryDeviceLogin = v }},
+	{prot.SettingsAttribute_PRIVACY_PROFILE_IMAGE_POST_TO_MYHOME, "Post profile picture changes to Home",
+		func(s *prot.Settings) bool { return s.PrivacyProfileImagePostToMyhome },
+		func(s *prot.Settings, v bool) { s.PrivacyProfileImagePostToMyhome = v }},
+	{prot.SettingsAttribute_PRIVACY_RECV_MESSAGES_FROM_NOT_FRIEND, "Receive messages from non-friends",
+		func(s *prot.Settings) bool { return s.PrivacyReceiveMessagesFromNotFriend },
+		func(s *prot.Settings, v bool) { s.PrivacyReceiveMessagesFromNotFriend = v }},
+}
+
+func (self *LineClient) RefreshSettings() (*prot.Settings, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var err error
+	self.Settings, err = self.client.GetSettings()
+	return self.Settings, err
+}
+
+func (self *LineClient) nextSettingsSeq() int32 {
+	self.settingsSeq += 1
+	if self.ownSettingsSeqs == nil {
+		self.ownSettingsSeqs = make(map[int32]bool)
+	}
+	self.ownSettingsSeqs[self.settingsSeq] = true
+	return self.settingsSeq
+}
+
+func (self *LineClient) IsOwnSettingsOperation(operation *prot.Operation) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	reqSeq := operation.GetReqSeq()
+	if !self.ownSettingsSeqs[reqSeq] {
+		return false
+	}
+	delete(self.ownSettingsSeqs, reqSeq)
+	return true
+}
+
+func (self *LineClient) UpdateSettingsAttribute(attr prot.SettingsAttribute, value string) (*prot.Settings, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.client.UpdateSettingsAttribute(self.nextSettingsSeq(), attr, value)
+	if err != nil {
+		return nil, err
+	}
+	self.Settings, err = self.client.GetSettings()
+	return self.Settings, err
+}
+
+func (self *LineClient) UpdateSettingsAttributes(attrs prot.SettingsAttribute, settings *prot.Settings) (*prot.Settings, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	_, err := self.client.UpdateSettingsAttributes(self.nextSettingsSeq(), int32(attrs), settings)
+	if err != nil {
+		return nil, err
+	}
+	self.Settings, err = self.client.GetSettings()
+	return self.Settings, err
+}
+
+func (self *LineClient) SetNotificationMuteExpiration(expiration int64) (*prot.Settings, error) {
+	return self.UpdateSettingsAttribute(prot.SettingsAttribute_NOTIFICATION_MUTE_EXPIRATION,
+		strconv.FormatInt(expiration, 10))
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	prot "github.com/carylorrk/goline/protocol"
+)
+
+var SpammerReasons = []prot.SpammerReason{
+	prot.SpammerReason_ADVERTISING,
+	prot.SpammerReason_GENDER_HARASSMENT,
+	prot.SpammerReason_HARASSMENT,
+	prot.SpammerReason_OTHER,
+}
+
+func SpammerReasonName(reason prot.SpammerReason) string {
+	switch reason {
+	case prot.SpammerReason_ADVERTISING:
+		return "Advertising"
+	case prot.SpammerReason_GENDER_HARASSMENT:
+		return "Sexual harassment"
+	case prot.SpammerReason_HARASSMENT:
+		return "Harassment"
+	}
+	return "Other"
+}
+
+func (self *LineClient) ReportSpammer(mid string, reasons []prot.SpammerReason, messageIds []string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.ReportSpammer(mid, reasons, messageIds)
+}
+
+func (self *LineClient) SendChatRemoved(chatId string, lastMessageId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.client.SendChatRemoved(0, chatId, lastMessageId)
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+	"github.com/godbus/dbus/prop"
+)
+
+const (
+	TRAY_ITEM_INTERFACE    = "org.kde.StatusNotifierItem"
+	TRAY_ITEM_PATH         = "/StatusNotifierItem"
+	TRAY_WATCHER_NAME      = "org.kde.StatusNotifierWatcher"
+	TRAY_WATCHER_PATH      = "/StatusNotifierWatcher"
+	TRAY_MENU_INTERFACE    = "com.canonical.dbusmenu"
+	TRAY_MENU_PATH         = "/MenuBar"
+	TRAY_ICON_NAME         = "internet-group-chat"
+	TRAY_ATTENTION_ICON    = "mail-unread"
+	TRAY_MENU_OPEN         = 1
+	TRAY_MENU_DND          = 2
+	TRAY_MENU_SEPARATOR    = 3
+	TRAY_MENU_QUIT         = 4
+	TRAY_STATUS_ACTIVE     = "Active"
+	TRAY_STATUS_ATTENTION  = "NeedsAttention"
+	TRAY_MENU_EVENT_CLICK  = "clicked"
+	TRAY_DBUS_PROPERTIES   = "org.freedesktop.DBus.Properties"
+	TRAY_DBUS_INTROSPECTOR = "org.freedesktop.DBus.Introspectable"
+)
+
+type trayToolTip struct {
+	IconName    string
+	IconPixmaps []trayPixmap
+	Title       string
+	Description string
+}
+
+type trayPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+type trayMenuLayout struct {
+	Id         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+type trayMenuProperties struct {
+	Id         int32
+	Properties map[string]dbus.Variant
+}
+
+type Tray struct {
+	OnActivate     func()
+	OnDoNotDisturb func(enabled bool)
+	OnQuit         func()
+
+	conn         *dbus.Conn
+	name         string
+	props        *prop.Properties
+	unreadCount  int64
+	doNotDisturb bool
+	revision     uint32
+	lock         sync.Mutex
+}
+
+type trayItem struct {
+	tray *Tray
+}
+
+type trayMenu struct {
+	tray *Tray
+}
+
+func NewTray(doNotDisturb bool) (*Tray, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	return NewTrayWithConn(conn, doNotDisturb)
+}
+
+func NewTrayWithConn(conn *dbus.Conn, doNotDisturb bool) (*Tray, error) {
+	tray := &Tray{
+		conn:         conn,
+		name:         fmt.Sprintf("%s-%d-1", TRAY_ITEM_INTERFACE, os.Getpid()),
+		doNotDisturb: doNotDisturb,
+		revision:     1,
+	}
+	err := tray.export()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(tray.name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		tray.unexport()
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		tray.unexport()
+		return nil, fmt.Errorf("Name %s already taken.", tray.name)
+	}
+
+	watcher := conn.Object(TRAY_WATCHER_NAME, TRAY_WATCHER_PATH)
+	call := watcher.Call(TRAY_WATCHER_NAME+".RegisterStatusNotifierItem", 0, tray.name)
+	if call.Err != nil {
+		tray.Close()
+		return nil, call.Err
+	}
+	return tray, nil
+}
+
+func (self *Tray) export() error {
+	item := &trayItem{self}
+	menu := &trayMenu{self}
+
+	err := self.conn.Export(item, TRAY_ITEM_PATH, TRAY_ITEM_INTERFACE)
+	if err != nil {
+		return err
+	}
+	err = self.conn.Export(menu, TRAY_MENU_PATH, TRAY_MENU_INTERFACE)
+	if err != nil {
+		return err
+	}
+
+	self.props = prop.New(self.conn, TRAY_ITEM_PATH, map[string]map[string]*prop.Prop{
+		TRAY_ITEM_INTERFACE: {
+			"Category":            {Value: "Communications", Emit: prop.EmitFalse},
+			"Id":                  {Value: "goline", Emit: prop.EmitFalse},
+			"Title":               {Value: self.getTitle(), Emit: prop.EmitFalse},
+			"Status":              {Value: self.getStatus(), Emit: prop.EmitFalse},
+			"WindowId":            {Value: int32(0), Emit: prop.EmitFalse},
+			"IconName":            {Value: TRAY_ICON_NAME, Emit: prop.EmitFalse},
+			"IconPixmap":          {Value: []trayPixmap{}, Emit: prop.EmitFalse},
+			"OverlayIconName":     {Value: "", Emit: prop.EmitFalse},
+			"OverlayIconPixmap":   {Value: []trayPixmap{}, Emit: prop.EmitFalse},
+			"AttentionIconName":   {Value: TRAY_ATTENTION_ICON, Emit: prop.EmitFalse},
+			"AttentionIconPixmap": {Value: []trayPixmap{}, Emit: prop.EmitFalse},
+			"AttentionMovieName":  {Value: "", Emit: prop.EmitFalse},
+			"ToolTip":             {Value: self.getToolTip(), Emit: prop.EmitFalse},
+			"ItemIsMenu":          {Value: false, Emit: prop.EmitFalse},
+			"Menu":                {Value: dbus.ObjectPath(TRAY_MENU_PATH), Emit: prop.EmitFalse},
+		},
+	})
+	prop.New(self.conn, TRAY_MENU_PATH, map[string]map[string]*prop.Prop{
+		TRAY_MENU_INTERFACE: {
+			"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+			"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+			"Status":        {Value: "normal", Emit: prop.EmitFalse},
+			"IconThemePath": {Value: []string{}, Emit: prop.EmitFalse},
+		},
+	})
+
+	itemNode := &introspect.Node{
+		Name: TRAY_ITEM_PATH,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       TRAY_ITEM_INTERFACE,
+				Methods:    introspect.Methods(item),
+				Properties: self.props.Introspection(TRAY_ITEM_INTERFACE),
+				Signals: []introspect.Signal{
+					{Name: "NewTitle"},
+					{Name: "NewIcon"},
+					{Name: "NewAttentionIcon"},
+					{Name: "NewToolTip"},
+					{Name: "NewStatus", Args: []introspect.Arg{{Name: "status", Type: "s"}}},
+				},
+			},
+		},
+	}
+	menuNode := &introspect.Node{
+		Name: TRAY_MENU_PATH,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:    TRAY_MENU_INTERFACE,
+				Methods: introspect.Methods(menu),
+				Signals: []introspect.Signal{
+					{Name: "LayoutUpdated", Args: []introspect.Arg{
+						{Name: "revision", Type: "u"},
+						{Name: "parent", Type: "i"},
+					}},
+				},
+			},
+		},
+	}
+	err = self.conn.Export(introspect.NewIntrospectable(itemNode), TRAY_ITEM_PATH, TRAY_DBUS_INTROSPECTOR)
+	if err != nil {
+		return err
+	}
+	return self.conn.Export(introspect.NewIntrospectable(menuNode), TRAY_MENU_PATH, TRAY_DBUS_INTROSPECTOR)
+}
+
+func (self *Tray) unexport() {
+	for _, path := range []dbus.ObjectPath{TRAY_ITEM_PATH, TRAY_MENU_PATH} {
+		for _, iface := range []string{TRAY_ITEM_INTERFACE, TRAY_MENU_INTERFACE,
+			TRAY_DBUS_PROPERTIES, TRAY_DBUS_INTROSPECTOR} {
+			self.conn.Export(nil, path, iface)
+		}
+	}
+}
+
+func (self *Tray) Close() {
+	self.unexport()
+	self.conn.ReleaseName(self.name)
+}
+
+func (self *Tray) getTitle() string {
+	if self.unreadCount > 0 {
+		return "Goline (" + strconv.FormatInt(self.unreadCount, 10) + ")"
+	}
+	return "Goline"
+}
+
+func (self *Tray) getStatus() string {
+	if self.unreadCount > 0 && !self.doNotDisturb {
+		return TRAY_STATUS_ATTENTION
+	}
+	return TRAY_STATUS_ACTIVE
+}
+
+func (self *Tray) getToolTip() trayToolTip {
+	description := "No unread messages"
+	if self.unreadCount == 1 {
+		description = "1 unread message"
+	} else if self.unreadCount > 1 {
+		description = strconv.FormatInt(self.unreadCount, 10) + " unread messages"
+	}
+	if self.doNotDisturb {
+		description += " (Do not disturb)"
+	}
+	return trayToolTip{IconName: TRAY_ICON_NAME, IconPixmaps: []trayPixmap{},
+		Title: "Goline", Description: description}
+}
+
+func (self *Tray) update() {
+	self.lock.Lock()
+	title := self.getTitle()
+	status := self.getStatus()
+	toolTip := self.getToolTip()
+	self.lock.Unlock()
+
+	self.props.SetMust(TRAY_ITEM_INTERFACE, "Title", title)
+	self.props.SetMust(TRAY_ITEM_INTERFACE, "Status", status)
+	self.props.SetMust(TRAY_ITEM_INTERFACE, "ToolTip", toolTip)
+	self.conn.Emit(TRAY_ITEM_PATH, TRAY_ITEM_INTERFACE+".NewTitle")
+	self.conn.Emit(TRAY_ITEM_PATH, TRAY_ITEM_INTERFACE+".NewToolTip")
+	self.conn.Emit(TRAY_ITEM_PATH, TRAY_ITEM_INTERFACE+".NewStatus", status)
+}
+
+func (self *Tray) SetUnreadCount(count int64) {
+	self.lock.Lock()
+	changed := self.unreadCount != count
+	self.unreadCount = count
+	self.lock.Unlock()
+	if changed {
+		self.update()
+	}
+}
+
+func (self *Tray) SetDoNotDisturb(enabled bool) {
+	self.lock.Lock()
+	self.doNotDisturb = enabled
+	self.revision += 1
+	revision := self.revision
+	self.lock.Unlock()
+	self.update()
+	self.conn.Emit(TRAY_MENU_PATH, TRAY_MENU_INTERFACE+".LayoutUpdated", revision, int32(0))
+}
+
+func (self *Tray) GetDoNotDisturb() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.doNotDisturb
+}
+
+func (self *trayItem) Activate(x int32, y int32) *dbus.Error {
+	if self.tray.OnActivate != nil {
+		self.tray.OnActivate()
+	}
+	return nil
+}
+
+func (self *trayItem) SecondaryActivate(x int32, y int32) *dbus.Error {
+	return self.Activate(x, y)
+}
+
+func (self *trayItem) ContextMenu(x int32, y int32) *dbus.Error {
+	return nil
+}
+
+func (self *trayItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+func (self *trayMenu) getItemProperties(id int32) map[string]dbus.Variant {
+	switch id {
+	case 0:
+		return map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")}
+	case TRAY_MENU_OPEN:
+		return map[string]dbus.Variant{"label": dbus.MakeVariant("Open Goline")}
+	case TRAY_MENU_DND:
+		state := int32(0)
+		if self.tray.GetDoNotDisturb() {
+			state = 1
+		}
+		return map[string]dbus.Variant{
+			"label":        dbus.MakeVariant("Do Not Disturb"),
+			"toggle-type":  dbus.MakeVariant("checkmark"),
+			"toggle-state": dbus.MakeVariant(state),
+		}
+	case TRAY_MENU_SEPARATOR:
+		return map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}
+	case TRAY_MENU_QUIT:
+		return map[string]dbus.Variant{"label": dbus.MakeVariant("Quit")}
+	}
+	return map[string]dbus.Variant{}
+}
+
+func (self *trayMenu) getLayout(id int32) trayMenuLayout {
+	layout := trayMenuLayout{Id: id, Properties: self.getItemProperties(id), Children: []dbus.Variant{}}
+	if id == 0 {
+		for _, child := range []int32{TRAY_MENU_OPEN, TRAY_MENU_DND, TRAY_MENU_SEPARATOR, TRAY_MENU_QUIT} {
+			layout.Children = append(layout.Children, dbus.MakeVariant(self.getLayout(child)))
+		}
+	}
+	return layout
+}
+
+func (self *trayMenu) GetLayout(parentId int32, recursionDepth int32, propertyNames []string) (uint32, trayMenuLayout, *dbus.Error) {
+	self.tray.lock.Lock()
+	revision := self.tray.revision
+	self.tray.lock.Unlock()
+	return revision, self.getLayout(parentId), nil
+}
+
+func (self *trayMenu) GetGroupProperties(ids []int32, propertyNames []string) ([]trayMenuProperties, *dbus.Error) {
+	properties := make([]trayMenuProperties, 0, len(ids))
+	for _, id := range ids {
+		properties = append(properties, trayMenuProperties{id, self.getItemProperties(id)})
+	}
+	return properties, nil
+}
+
+func (self *trayMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	value, ok := self.getItemProperties(id)[name]
+	if !ok {
+		return dbus.MakeVariant(""), nil
+	}
+	return value, nil
+}
+
+func (self *trayMenu) Event(id int32, eventId string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventId != TRAY_MENU_EVENT_CLICK {
+		return nil
+	}
+	switch id {
+	case TRAY_MENU_OPEN:
+		if self.tray.OnActivate != nil {
+			go self.tray.OnActivate()
+		}
+	case TRAY_MENU_DND:
+		enabled := !self.tray.GetDoNotDisturb()
+		go func() {
+			self.tray.SetDoNotDisturb(enabled)
+			if self.tray.OnDoNotDisturb != nil {
+				self.tray.OnDoNotDisturb(enabled)
+			}
+		}()
+	case TRAY_MENU_QUIT:
+		if self.tray.OnQuit != nil {
+			go self.tray.OnQuit()
+		}
+	}
+	return nil
+}
+
+func (self *trayMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
@@ -55,9 +55,14 @@ func (self *LineGroupWrapper) Refresh(client *LineClient) {
 	client.RefreshGroups()
 }
 
+func (self *LineGroupWrapper) GetGroup() *prot.Group {
+	return self.group
+}
+
 type LineRoomWrapper struct {
-	room *prot.Room
-	name string
+	room    *prot.Room
+	name    string
+	nameKey string
 }
 
 func NewLineRoomWrapper(room *prot.Room) *LineRoomWrapper {
@@ -69,8 +74,14 @@ func (self *LineRoomWrapper) GetId() string {
 }
 
 func (self *LineRoomWrapper) GetName() string {
-	if self.name == "" {
-		contacts := self.room.GetContacts()
+	contacts := self.room.GetContacts()
+	nameKey := ""
+	for _, contact := range contacts {
+		nameKey += contact.GetMid() + contact.GetDisplayName()
+	}
+	if self.name == "" || self.nameKey != nameKey {
+		self.name = ""
+		self.nameKey = nameKey
 		for idx, contact := range contacts {
 			if idx >= 3 {
 				self.name += "..."
@@ -86,3 +97,7 @@ func (self *LineRoomWrapper) GetName() string {
 func (self *LineRoomWrapper) Refresh(client *LineClient) {
 	client.RefreshRooms()
 }
+
+func (self *LineRoomWrapper) GetRoom() *prot.Room {
+	return self.room
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"io"
-	"net/http"
+	"github.com/carylorrk/goline/api"
+	prot "github.com/carylorrk/goline/protocol"
 	"os"
+	"os/exec"
 
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/gdkpixbuf"
 	"github.com/mattn/go-gtk/gtk"
 )
 
@@ -20,6 +23,25 @@ func RunErrorMessage(parent *gtk.Window, format string) {
 	dialog.Destroy()
 }
 
+func RunConfirmMessage(parent *gtk.Window, format string) bool {
+	dialog := gtk.NewMessageDialog(parent, 0, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, format)
+	res := dialog.Run()
+	dialog.Destroy()
+	return res == gtk.RESPONSE_YES
+}
+
+func OpenUri(uri string) {
+	err := exec.Command("xdg-open", uri).Start()
+	if err != nil {
+		goline.LoggerPrintln(err)
+	}
+}
+
+func CopyToClipboard(text string) {
+	clipboard := gtk.NewClipboardGetForDisplay(gdk.DisplayGetDefault(), gdk.SELECTION_CLIPBOARD)
+	clipboard.SetText(text)
+}
+
 func CheckFileNotExist(path string) bool {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return true
@@ -28,29 +50,70 @@ func CheckFileNotExist(path string) bool {
 }
 
 func DownloadFile(url, filePath string) error {
-	file, err := os.Create(filePath)
+	err := goline.downloads.Fetch(url, filePath)
 	if err != nil {
-		goline.LoggerPrintln(err)
-		return err
+		goline.LoggerPrintln(err, url)
 	}
-	defer file.Close()
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	return err
+}
+
+func DownloadPublicFile(url, filePath string) error {
+	err := goline.downloads.FetchPublic(url, filePath)
 	if err != nil {
-		goline.LoggerPrintln(err)
-		return err
+		goline.LoggerPrintln(err, url)
 	}
-	req.Header = *goline.client.GetHeader()
-	res, err := client.Do(req)
+	return err
+}
+
+func LoginWithAuthToken(authToken string) error {
+	err := goline.client.AuthTokenLogin(authToken)
+	for _, loginErr := range goline.client.LoginErrors {
+		goline.LoggerPrintln(loginErr)
+	}
+	return err
+}
+
+func SetImageFromFileAtScale(image *gtk.Image, filePath string, size int) {
+	image.Clear()
+	if filePath == "" {
+		return
+	}
+	pixbuf, err := gdkpixbuf.NewPixbufFromFileAtScale(filePath, size, size, true)
 	if err != nil {
 		goline.LoggerPrintln(err)
-		return err
+		return
 	}
-	defer res.Body.Close()
+	image.SetFromPixbuf(pixbuf)
+}
 
-	_, err = io.Copy(file, res.Body)
+func DownloadPicture(id string, status string, url string) string {
+	filePath, err := goline.avatars.Get(id, status, url)
 	if err != nil {
 		goline.LoggerPrintln(err)
+		return ""
 	}
-	return err
+	return filePath
+}
+
+func DownloadContactPicture(contact *prot.Contact) string {
+	status, url := api.GetContactPicture(contact)
+	return DownloadPicture(contact.GetMid(), status, url)
+}
+
+func LoadAvatar(image *gtk.Image, entity api.LineEntity, size int, valid func() bool) {
+	go func() {
+		filePath, err := goline.avatars.GetEntity(entity, size)
+		if err != nil {
+			goline.LoggerPrintln(err)
+			return
+		}
+		if filePath == "" {
+			return
+		}
+		gdk.ThreadsEnter()
+		if valid() {
+			image.SetFromFile(filePath)
+		}
+		gdk.ThreadsLeave()
+	}()
 }